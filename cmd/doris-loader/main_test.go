@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFe accepts stream loads, failing the payloads containing "fail".
+func newFe(t *testing.T) *doristest.Server {
+	t.Helper()
+
+	return doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		if bytes.Contains(load.Payload, []byte("fail")) {
+			return doristest.Response{Body: `{"Status": "Fail", "Message": "too many filtered rows", "Label": "` + load.Header.Get("label") + `"}`}
+		}
+
+		return doristest.Success(load)
+	}))
+}
+
+func TestRunStdin(t *testing.T) {
+	t.Log("stdin should be loaded with the flags mapped to the stream load header")
+
+	fe := newFe(t)
+	var stdout, stderr bytes.Buffer
+
+	code := run(context.Background(), []string{
+		"-fe", fe.Host(),
+		"-db", "test_db",
+		"-table", "users",
+		"-user", "root",
+		"-format", "csv",
+		"-column-separator", "|",
+		"-columns", "name,age",
+		"-label", "batch_1",
+		"-max-filter-ratio", "0.1",
+		"-header", "timeout=600",
+		"-output", "json",
+	}, strings.NewReader("John Doe|30\n"), &stdout, &stderr)
+
+	assert.Equal(t, exitSuccess, code, stderr.String())
+	assert.Len(t, fe.Loads(), 1)
+
+	header := fe.Loads()[0].Header
+	assert.Equal(t, "csv", header.Get("format"))
+	assert.Equal(t, "|", header.Get("column_separator"))
+	assert.Equal(t, "name,age", header.Get("columns"))
+	assert.Equal(t, "batch_1", header.Get("label"))
+	assert.Equal(t, "0.1", header.Get("max_filter_ratio"))
+	assert.Equal(t, "600", header.Get("timeout"))
+
+	var reports []report
+	assert.NoError(t, json.Unmarshal(stdout.Bytes(), &reports))
+	assert.Len(t, reports, 1)
+	assert.Equal(t, "-", reports[0].Source)
+	assert.Equal(t, "batch_1", reports[0].Label)
+	assert.Equal(t, "Success", reports[0].Result.Status)
+}
+
+func TestRunFiles(t *testing.T) {
+	t.Log("files and globs should be loaded and a failed load should exit with a non-zero code")
+
+	fe := newFe(t)
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.json": `{"name": "John Doe"}`,
+		"b.json": `{"name": "Jane Doe"}`,
+		"c.txt":  `{"name": "fail"}`,
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{
+		"-fe", fe.Host(),
+		"-db", "test_db",
+		"-table", "users",
+		filepath.Join(dir, "*.json"),
+		filepath.Join(dir, "c.txt"),
+	}, nil, &stdout, &stderr)
+
+	assert.Equal(t, exitFailure, code)
+	assert.Len(t, fe.Loads(), 3)
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "SOURCE"))
+	assert.Contains(t, lines[1], "a.json")
+	assert.Contains(t, lines[1], "Success")
+	assert.Contains(t, lines[3], "c.txt")
+	assert.Contains(t, lines[3], "too many filtered rows")
+}
+
+func TestRunUsage(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Args            []string
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "missing database should exit with the usage code",
+			Args:            []string{"-fe", "127.0.0.1:8030", "-table", "users"},
+		},
+		{
+			TestDescription: "unsupported format should exit with the usage code",
+			Args:            []string{"-fe", "127.0.0.1:8030", "-db", "test_db", "-table", "users", "-format", "parquet"},
+		},
+		{
+			TestDescription: "a label shared by several files should exit with the usage code",
+			Args:            []string{"-fe", "127.0.0.1:8030", "-db", "test_db", "-table", "users", "-label", "a", "a.json", "b.json"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), tc.Args, nil, &stdout, &stderr)
+
+		assert.Equal(t, exitUsage, code)
+		assert.NotEmpty(t, stderr.String())
+		assert.Empty(t, stdout.String())
+	}
+}
+
+func TestRunSchemaValidationFailure(t *testing.T) {
+	t.Log("a failed schema validation should exit with the failure code instead of the usage code, without loading")
+
+	fe := doristest.NewServer(t, doristest.WithRoute("/api/test_db/users/_schema", doristest.Response{Body: `{
+		"msg": "success",
+		"code": 0,
+		"data": {
+			"properties": [{"name": "name", "type": "VARCHAR(50)", "aggregation_type": "", "comment": "", "is_nullable": "Yes"}],
+			"keysType": "DUP_KEYS",
+			"status": 200
+		},
+		"count": 0
+	}`}))
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{
+		"-fe", fe.Host(),
+		"-db", "test_db",
+		"-table", "users",
+		"-columns", "nmae",
+		"-validate-schema",
+	}, strings.NewReader(`{"name": "John Doe"}`), &stdout, &stderr)
+
+	assert.Equal(t, exitFailure, code)
+	assert.Contains(t, stderr.String(), "column nmae does not exist")
+	assert.Empty(t, fe.Loads())
+}
+
+func TestRunRejectFile(t *testing.T) {
+	t.Log("rejected rows should be appended to the reject file, and failing to write them should be reported on stderr")
+
+	fe := doristest.NewServer(t, doristest.WithRoute("/api/test_db/users/_schema", doristest.Response{Body: `{
+		"msg": "success",
+		"code": 0,
+		"data": {
+			"properties": [
+				{"name": "name", "type": "VARCHAR(50)", "aggregation_type": "", "comment": "", "is_nullable": "Yes"},
+				{"name": "age", "type": "INT", "aggregation_type": "", "comment": "", "is_nullable": "Yes"}
+			],
+			"keysType": "DUP_KEYS",
+			"status": 200
+		},
+		"count": 0
+	}`}))
+	payload := "{\"name\": \"John Doe\", \"age\": 30}\n{\"name\": \"Jane Doe\", \"age\": \"thirty\"}\n"
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rejects.jsonl")
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{
+		"-fe", fe.Host(),
+		"-db", "test_db",
+		"-table", "users",
+		"-reject", path,
+	}, strings.NewReader(payload), &stdout, &stderr)
+
+	assert.Equal(t, exitSuccess, code, stderr.String())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "thirty")
+	assert.Empty(t, stderr.String())
+
+	stdout.Reset()
+	code = run(context.Background(), []string{
+		"-fe", fe.Host(),
+		"-db", "test_db",
+		"-table", "users",
+		"-reject", filepath.Join(dir, "missing", "rejects.jsonl"),
+	}, strings.NewReader(payload), &stdout, &stderr)
+
+	assert.Equal(t, exitSuccess, code, stderr.String())
+	assert.Contains(t, stderr.String(), "rejects.jsonl")
+}
+
+func TestRunConfig(t *testing.T) {
+	t.Log("the job of the config should be loaded with the flags applied on top")
+
+	fe := newFe(t)
+	path := filepath.Join(t.TempDir(), "jobs.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+cluster:
+  fe_nodes: [`+fe.Host()+`]
+jobs:
+  users:
+    database: test_db
+    table: users
+    format: csv
+`), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), []string{"-config", path, "-label", "batch_1"}, strings.NewReader("John Doe,30\n"), &stdout, &stderr)
+
+	assert.Equal(t, exitSuccess, code, stderr.String())
+	assert.Len(t, fe.Loads(), 1)
+	assert.Equal(t, "csv", fe.Loads()[0].Header.Get("format"))
+	assert.Equal(t, "batch_1", fe.Loads()[0].Header.Get("label"))
+}
+
+func TestRunDSN(t *testing.T) {
+	t.Log("the loader should be created from $DORIS_DSN if no flag gives it")
+
+	fe := newFe(t)
+	t.Setenv("DORIS_DSN", "doris://root:changeme@"+fe.Host()+"/test_db/users?format=csv&column_separator=|")
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), nil, strings.NewReader("John Doe|30\n"), &stdout, &stderr)
+
+	assert.Equal(t, exitSuccess, code, stderr.String())
+	assert.Len(t, fe.Loads(), 1)
+	assert.Equal(t, "|", fe.Loads()[0].Header.Get("column_separator"))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/raaaaaaaay86/doris-loader/loader"
+)
+
+// report is the outcome of loading one source, or one chunk of it.
+type report struct {
+	Source string                   `json:"source"`
+	Label  string                   `json:"label,omitempty"`
+	Result *loader.StreamLoadResult `json:"result,omitempty"`
+	Error  string                   `json:"error,omitempty"`
+}
+
+// success reports whether the source was loaded, including by a previous run with the same label.
+func (r report) success() bool {
+	return r.Error == "" && (r.Result == nil || r.Result.IsLoaded())
+}
+
+// newReport creates a report of a load. A nil result without error means the source was skipped.
+func newReport(source string, label string, result *loader.StreamLoadResult, err error) report {
+	r := report{Source: source, Label: label, Result: result}
+	if result != nil && r.Label == "" {
+		r.Label = result.Label
+	}
+
+	if err != nil {
+		r.Error = err.Error()
+	} else if result != nil && !result.IsLoaded() {
+		r.Error = result.Error().Error()
+	}
+
+	return r
+}
+
+// loadSource loads stdin, a file or a glob. A source with glob metacharacters is loaded by LoadGlob, a file by LoadFile or LoadFileParallel.
+func loadSource(
+	ctx context.Context,
+	ld *loader.StreamLoader,
+	source string,
+	parallel bool,
+	stdin io.Reader,
+) []report {
+	switch {
+	case source == stdinName:
+		payload, err := io.ReadAll(stdin)
+		if err != nil {
+			return []report{newReport(source, "", nil, err)}
+		}
+
+		result, err := ld.LoadReader(ctx, bytes.NewReader(payload), "")
+		return []report{newReport(source, "", result, err)}
+	case strings.ContainsAny(source, "*?["):
+		batch, err := ld.LoadGlob(ctx, source)
+		if err != nil {
+			return []report{newReport(source, "", nil, err)}
+		}
+
+		if len(batch.Files) == 0 {
+			return []report{newReport(source, "", nil, fmt.Errorf("no file matches %s", source))}
+		}
+
+		var reports []report
+		for _, file := range batch.Files {
+			reports = append(reports, newReport(file.Filename, file.Label, file.Result, file.Err))
+		}
+
+		return reports
+	case parallel:
+		parallelResult, err := ld.LoadFileParallel(ctx, source)
+		if err != nil {
+			return []report{newReport(source, "", nil, err)}
+		}
+
+		var reports []report
+		for _, chunk := range parallelResult.Chunks {
+			reports = append(reports, newReport(fmt.Sprintf("%s#%d", source, chunk.Index), chunk.Label, chunk.Result, chunk.Err))
+		}
+
+		return reports
+	default:
+		result, err := ld.LoadFile(ctx, source)
+		return []report{newReport(source, "", result, err)}
+	}
+}
+
+// printReports prints the reports as a table or a JSON array.
+func printReports(w io.Writer, output string, reports []report) error {
+	if output == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(reports)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tLABEL\tSTATUS\tTOTAL\tLOADED\tFILTERED\tTIME(ms)\tERROR")
+
+	for _, r := range reports {
+		status := "Skipped"
+		var total, loaded, filtered, timeMs int
+		if r.Result != nil {
+			status = r.Result.Status
+			total, loaded, filtered, timeMs = r.Result.NumberTotalRows, r.Result.NumberLoadedRows, r.Result.NumberFilteredRows, r.Result.LoadTimeMs
+		} else if r.Error != "" {
+			status = "Failed"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\n", r.Source, r.Label, status, total, loaded, filtered, timeMs, r.Error)
+	}
+
+	return tw.Flush()
+}
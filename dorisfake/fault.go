@@ -0,0 +1,288 @@
+package dorisfake
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fault is a misbehavior of a node on a stream load request.
+type Fault struct {
+	kind   faultKind
+	delay  time.Duration
+	status int
+}
+
+type faultKind int
+
+const (
+	faultPass faultKind = iota
+	faultDelay
+	faultDrop
+	faultStatus
+	faultHtml
+	faultPublishTimeout
+	faultLoseResponse
+)
+
+// Pass handles the request normally. It's used to script faults on later requests only.
+func Pass() Fault {
+	return Fault{kind: faultPass}
+}
+
+// Delay handles the request normally after the duration, or drops it if the client gives up before.
+func Delay(duration time.Duration) Fault {
+	return Fault{kind: faultDelay, delay: duration}
+}
+
+// DropConnection closes the connection after reading the beginning of the request body, like a node crashing mid-load.
+func DropConnection() Fault {
+	return Fault{kind: faultDrop}
+}
+
+// StatusError answers with the HTTP status and a plain text body, like an overloaded node.
+func StatusError(status int) Fault {
+	return Fault{kind: faultStatus, status: status}
+}
+
+// HtmlError answers with the HTTP status and an HTML page, like a proxy in front of the node.
+func HtmlError(status int) Fault {
+	return Fault{kind: faultHtml, status: status}
+}
+
+// PublishTimeout commits the load but reports the "Publish Timeout" status, meaning the data becomes visible later. It only applies to BE nodes.
+func PublishTimeout() Fault {
+	return Fault{kind: faultPublishTimeout}
+}
+
+// LoseResponse commits the load and closes the connection without answering, so that the client retries a committed label. It only applies to BE nodes.
+func LoseResponse() Fault {
+	return Fault{kind: faultLoseResponse}
+}
+
+// FaultPlan is a script of faults. Each stream load request received by a node takes the next fault queued for the node, or else the next one queued for its role. Requests find no fault once the queues are empty.
+//
+//	plan := dorisfake.NewFaultPlan().
+//		OnFe(dorisfake.StatusError(503)).
+//		OnBe(dorisfake.Pass(), dorisfake.LoseResponse())
+type FaultPlan struct {
+	mu     sync.Mutex
+	queues map[string][]Fault
+}
+
+// Keys of the role queues of FaultPlan.
+const (
+	roleFe = "fe"
+	roleBe = "be"
+)
+
+// NewFaultPlan creates an empty plan.
+func NewFaultPlan() *FaultPlan {
+	return &FaultPlan{queues: map[string][]Fault{}}
+}
+
+// OnFe queues faults for the stream load requests received by any FE node.
+func (p *FaultPlan) OnFe(faults ...Fault) *FaultPlan {
+	return p.queue(roleFe, faults)
+}
+
+// OnBe queues faults for the stream load requests received by any BE node.
+func (p *FaultPlan) OnBe(faults ...Fault) *FaultPlan {
+	return p.queue(roleBe, faults)
+}
+
+// OnNode queues faults for the stream load requests received by the node, taken before the ones of its role.
+func (p *FaultPlan) OnNode(addr string, faults ...Fault) *FaultPlan {
+	return p.queue(addr, faults)
+}
+
+// Pending returns the number of faults not taken yet.
+func (p *FaultPlan) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending := 0
+	for _, faults := range p.queues {
+		pending += len(faults)
+	}
+
+	return pending
+}
+
+// queue appends the faults to the queue of the key.
+func (p *FaultPlan) queue(key string, faults []Fault) *FaultPlan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.queues[key] = append(p.queues[key], faults...)
+
+	return p
+}
+
+// next takes the fault of a request received by the node.
+func (p *FaultPlan) next(role string, addr string) Fault {
+	if p == nil {
+		return Pass()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, key := range []string{addr, role} {
+		if faults := p.queues[key]; len(faults) > 0 {
+			p.queues[key] = faults[1:]
+			return faults[0]
+		}
+	}
+
+	return Pass()
+}
+
+// inject applies the fault before the request is handled. It reports whether the request was answered or dropped, so that it must not be handled.
+func (f Fault) inject(w http.ResponseWriter, r *http.Request) bool {
+	switch f.kind {
+	case faultDelay:
+		// The server only notices a client giving up once the body is consumed, so it's buffered before waiting.
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return true
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		select {
+		case <-r.Context().Done():
+			return true
+		case <-time.After(f.delay):
+		}
+	case faultDrop:
+		_, _ = io.ReadFull(r.Body, make([]byte, 1))
+		dropConnection(w)
+		return true
+	case faultStatus:
+		_, _ = io.Copy(io.Discard, r.Body)
+		http.Error(w, http.StatusText(f.status), f.status)
+		return true
+	case faultHtml:
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(f.status)
+		_, _ = fmt.Fprintf(w, "<html>\r\n<head><title>%[1]d %[2]s</title></head>\r\n<body>\r\n<center><h1>%[1]d %[2]s</h1></center>\r\n</body>\r\n</html>\r\n", f.status, http.StatusText(f.status))
+		return true
+	}
+
+	return false
+}
+
+// dropConnection closes the connection of the request without answering.
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	_ = conn.Close()
+}
+
+// offlineListener refuses the connections while its node is offline, by closing its socket until the node is back online and listens on the same address again.
+type offlineListener struct {
+	addr net.Addr
+
+	mu      sync.Mutex
+	inner   net.Listener // nil while offline
+	closed  bool
+	changed chan struct{} // closed and replaced whenever the state changes
+}
+
+func newOfflineListener(inner net.Listener) *offlineListener {
+	return &offlineListener{
+		addr:    inner.Addr(),
+		inner:   inner,
+		changed: make(chan struct{}),
+	}
+}
+
+func (l *offlineListener) Accept() (net.Conn, error) {
+	for {
+		l.mu.Lock()
+		inner, closed, changed := l.inner, l.closed, l.changed
+		l.mu.Unlock()
+
+		if closed {
+			return nil, net.ErrClosed
+		}
+
+		if inner == nil {
+			<-changed
+			continue
+		}
+
+		conn, err := inner.Accept()
+		if err == nil {
+			return conn, nil
+		}
+
+		l.mu.Lock()
+		replaced := !l.closed && l.inner != inner
+		l.mu.Unlock()
+
+		// The socket was closed by taking the node offline, not by closing the server.
+		if replaced {
+			continue
+		}
+
+		return nil, err
+	}
+}
+
+func (l *offlineListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.closed = true
+	l.notify()
+
+	if l.inner == nil {
+		return nil
+	}
+
+	return l.inner.Close()
+}
+
+func (l *offlineListener) Addr() net.Addr {
+	return l.addr
+}
+
+// setOffline closes the socket of the listener, or listens on its address again.
+func (l *offlineListener) setOffline(offline bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed || offline == (l.inner == nil) {
+		return nil
+	}
+	defer l.notify()
+
+	if offline {
+		inner := l.inner
+		l.inner = nil
+		return inner.Close()
+	}
+
+	inner, err := net.Listen(l.addr.Network(), l.addr.String())
+	if err != nil {
+		return err
+	}
+	l.inner = inner
+
+	return nil
+}
+
+// notify wakes the Accept waiting for a state change. The caller must hold the lock.
+func (l *offlineListener) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
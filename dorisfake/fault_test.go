@@ -0,0 +1,197 @@
+package dorisfake_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFaultPlan(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Plan            *dorisfake.FaultPlan
+		ExpectAttempts  int
+		ExpectStatus    string
+		ExpectErr       bool
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "5xx and HTML errors of the FE should be retried",
+			Plan:            dorisfake.NewFaultPlan().OnFe(dorisfake.StatusError(http.StatusServiceUnavailable), dorisfake.HtmlError(http.StatusBadGateway)),
+			ExpectAttempts:  3,
+			ExpectStatus:    "Success",
+		},
+		{
+			TestDescription: "a connection dropped by the BE mid-body should be retried",
+			Plan:            dorisfake.NewFaultPlan().OnBe(dorisfake.DropConnection()),
+			ExpectAttempts:  2,
+			ExpectStatus:    "Success",
+		},
+		{
+			TestDescription: "a response lost after the commit should be retried and reported as already loaded",
+			Plan:            dorisfake.NewFaultPlan().OnBe(dorisfake.LoseResponse()),
+			ExpectAttempts:  2,
+			ExpectStatus:    "Label Already Exists",
+		},
+		{
+			TestDescription: "Publish Timeout should be reported without retrying",
+			Plan:            dorisfake.NewFaultPlan().OnBe(dorisfake.PublishTimeout()),
+			ExpectAttempts:  1,
+			ExpectStatus:    "Publish Timeout",
+		},
+		{
+			TestDescription: "the last error should be returned once the retries are exhausted",
+			Plan: dorisfake.NewFaultPlan().OnFe(
+				dorisfake.StatusError(http.StatusInternalServerError),
+				dorisfake.StatusError(http.StatusInternalServerError),
+				dorisfake.StatusError(http.StatusInternalServerError),
+			),
+			ExpectAttempts: 3,
+			ExpectErr:      true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		server := newServer(t, dorisfake.WithFaultPlan(tc.Plan))
+
+		attempts := 0
+		ld, err := loader.NewStreamLoader(
+			server.FeNodes(),
+			"test_db",
+			"users",
+			loader.WithUsername("root"),
+			loader.WithLabel("fault_label"),
+			loader.WithRetryInterval(time.Millisecond),
+			loader.WithHooks(loader.Hooks{
+				OnAttempt: func(ctx context.Context, event loader.HookEvent) { attempts++ },
+			}),
+		)
+		assert.NoError(t, err)
+
+		result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe", "age": 30}`), "")
+		assert.Equal(t, tc.ExpectAttempts, attempts)
+		assert.Zero(t, tc.Plan.Pending())
+
+		if tc.ExpectErr {
+			assert.Error(t, err)
+			assert.Empty(t, server.Rows("test_db", "users"))
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ExpectStatus, result.Status)
+		assert.Len(t, server.Rows("test_db", "users"), 1)
+	}
+}
+
+func TestFaultDelay(t *testing.T) {
+	t.Log("a delayed response should be abandoned when the context is done, and the next request should pass")
+
+	plan := dorisfake.NewFaultPlan().OnBe(dorisfake.Delay(time.Minute))
+	server := newServer(t, dorisfake.WithFaultPlan(plan))
+
+	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"))
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = ld.LoadReader(ctx, strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+}
+
+func TestFaultOnNode(t *testing.T) {
+	t.Log("faults queued for a node should only affect that node, before the faults of its role")
+
+	plan := dorisfake.NewFaultPlan()
+	server := newServer(t, dorisfake.WithNodes(1, 2), dorisfake.WithFaultPlan(plan))
+	bad, good := server.BeNodes()[0], server.BeNodes()[1]
+	plan.OnNode(bad, dorisfake.StatusError(http.StatusServiceUnavailable)).OnBe(dorisfake.PublishTimeout())
+
+	ld, err := loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithBeNodes([]string{bad, good}),
+		loader.WithRetryInterval(time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.NoError(t, err)
+	assert.Equal(t, "Publish Timeout", result.Status)
+
+	loads := server.Loads()
+	if assert.Len(t, loads, 1) {
+		assert.Equal(t, good, loads[0].BeNode)
+	}
+}
+
+func TestOfflineNodes(t *testing.T) {
+	t.Log("an offline FE should be failed over to the next FE")
+
+	server := newServer(t, dorisfake.WithNodes(2, 2))
+	feNodes, beNodes := server.FeNodes(), server.BeNodes()
+	assert.NoError(t, server.SetOffline(feNodes[0], true))
+
+	ld, err := loader.NewStreamLoader(
+		feNodes,
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithBeNodes(beNodes),
+		loader.WithRetryInterval(time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	for _, status := range ld.FeStatus() {
+		assert.Equal(t, status.Addr != feNodes[0], status.Healthy, status.Addr)
+	}
+
+	t.Log("an offline BE selected by the loader should be marked down and skipped")
+	assert.NoError(t, server.SetOffline(feNodes[0], false))
+	assert.NoError(t, server.SetOffline(beNodes[0], true))
+	server.Reset()
+
+	for i := 0; i < 3; i++ {
+		result, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+		assert.NoError(t, err)
+		assert.True(t, result.IsSuccess())
+	}
+	for _, load := range server.Loads() {
+		assert.Equal(t, beNodes[1], load.BeNode)
+	}
+	for _, status := range ld.BeStatus() {
+		assert.Equal(t, status.Addr != beNodes[0], status.Healthy, status.Addr)
+	}
+
+	t.Log("FE redirects should skip offline BE nodes")
+	ld, err = loader.NewStreamLoader(feNodes, "test_db", "users", loader.WithUsername("root"))
+	assert.NoError(t, err)
+
+	server.Reset()
+	for i := 0; i < 2; i++ {
+		_, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+		assert.NoError(t, err)
+	}
+	for _, load := range server.Loads() {
+		assert.Equal(t, beNodes[1], load.BeNode)
+	}
+}
@@ -0,0 +1,364 @@
+package dorisfake
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/dorisconv"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+)
+
+// loadOptions are the stream load headers understood by the fake.
+type loadOptions struct {
+	format          string   // csv, csv_with_names, csv_with_names_and_types or json
+	separator       string   // Column separator of CSV
+	delimiter       string   // Line delimiter
+	columns         []string // Source columns, nil for the table columns
+	strict          bool     // Whether values failing the conversion filter the row instead of becoming NULL
+	maxFilterRatio  float64  // Maximum ratio of filtered rows
+	readJsonByLine  bool     // Whether each line is a JSON document
+	stripOuterArray bool     // Whether a JSON document is an array of rows
+	jsonPaths       []string // JSON paths of the source columns
+}
+
+// parseOptions reads the load options from the stream load header.
+func parseOptions(header http.Header) (loadOptions, error) {
+	options := loadOptions{
+		format:    "csv",
+		separator: "\t",
+		delimiter: "\n",
+	}
+
+	if format := strings.ToLower(header.Get("format")); format != "" {
+		switch format {
+		case "csv", "csv_with_names", "csv_with_names_and_types", "json":
+			options.format = format
+		default:
+			return options, fmt.Errorf("unsupported format: %s", format)
+		}
+	}
+
+	if separator := header.Get("column_separator"); separator != "" {
+		options.separator = unescape(separator)
+	}
+
+	if delimiter := header.Get("line_delimiter"); delimiter != "" {
+		options.delimiter = unescape(delimiter)
+	}
+
+	if columns := header.Get("columns"); columns != "" {
+		for _, column := range strings.Split(columns, ",") {
+			options.columns = append(options.columns, strings.Trim(strings.TrimSpace(column), "`"))
+		}
+	}
+
+	var err error
+	if options.strict, err = boolHeader(header, "strict_mode"); err != nil {
+		return options, err
+	}
+
+	if options.readJsonByLine, err = boolHeader(header, "read_json_by_line"); err != nil {
+		return options, err
+	}
+
+	if options.stripOuterArray, err = boolHeader(header, "strip_outer_array"); err != nil {
+		return options, err
+	}
+
+	if ratio := header.Get("max_filter_ratio"); ratio != "" {
+		if options.maxFilterRatio, err = strconv.ParseFloat(ratio, 64); err != nil {
+			return options, fmt.Errorf("invalid max_filter_ratio: %s", ratio)
+		}
+	}
+
+	if paths := header.Get("jsonpaths"); paths != "" {
+		if err := json.Unmarshal([]byte(paths), &options.jsonPaths); err != nil {
+			return options, fmt.Errorf("invalid jsonpaths: %s", paths)
+		}
+	}
+
+	return options, nil
+}
+
+// parseRows parses the payload into rows of the table. It returns the valid rows, the filtered ones and the total number of rows.
+func parseRows(
+	schema loader.TableSchema,
+	options loadOptions,
+	payload []byte,
+) ([]Row, []loader.ErrorRow, int, error) {
+	var rows []Row
+	var filtered []loader.ErrorRow
+
+	add := func(raw string, fields map[string]*string, err error) {
+		if err == nil {
+			var row Row
+			if row, err = convertRow(schema, fields, options.strict); err == nil {
+				rows = append(rows, row)
+				return
+			}
+		}
+
+		filtered = append(filtered, loader.ErrorRow{Reason: err.Error(), RawRow: raw})
+	}
+
+	if options.format == "json" {
+		documents, err := jsonDocuments(options, payload)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+
+		for _, document := range documents {
+			fields, err := jsonFields(schema, options, document)
+			add(string(document), fields, err)
+		}
+
+		return rows, filtered, len(rows) + len(filtered), nil
+	}
+
+	lines := strings.Split(string(payload), options.delimiter)
+	columns := options.columns
+
+	switch options.format {
+	case "csv_with_names", "csv_with_names_and_types":
+		if len(lines) == 0 {
+			break
+		}
+
+		columns = nil
+		for _, name := range strings.Split(lines[0], options.separator) {
+			columns = append(columns, strings.Trim(strings.TrimSpace(name), "`"))
+		}
+
+		lines = lines[1:]
+		if options.format == "csv_with_names_and_types" && len(lines) > 0 {
+			lines = lines[1:]
+		}
+	}
+
+	if columns == nil {
+		columns = schema.ColumnNames()
+	}
+
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+
+		fields, err := csvFields(columns, line, options.separator)
+		add(line, fields, err)
+	}
+
+	return rows, filtered, len(rows) + len(filtered), nil
+}
+
+// csvFields maps the values of a CSV line to the source columns.
+func csvFields(columns []string, line string, separator string) (map[string]*string, error) {
+	values := strings.Split(line, separator)
+	if len(values) != len(columns) {
+		comparison := "less"
+		if len(values) > len(columns) {
+			comparison = "more"
+		}
+
+		return nil, fmt.Errorf("actual column number in csv file is %s than schema column number.actual number: %d, schema column number: %d", comparison, len(values), len(columns))
+	}
+
+	fields := map[string]*string{}
+	for i, column := range columns {
+		value := values[i]
+		if value == `\N` {
+			fields[strings.ToLower(column)] = nil
+			continue
+		}
+
+		fields[strings.ToLower(column)] = &value
+	}
+
+	return fields, nil
+}
+
+// jsonDocuments splits the payload into the JSON documents of the rows.
+func jsonDocuments(options loadOptions, payload []byte) ([]json.RawMessage, error) {
+	var chunks [][]byte
+	if options.readJsonByLine {
+		for _, line := range bytes.Split(payload, []byte(options.delimiter)) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				chunks = append(chunks, line)
+			}
+		}
+	} else if len(bytes.TrimSpace(payload)) > 0 {
+		chunks = append(chunks, payload)
+	}
+
+	var documents []json.RawMessage
+	for _, chunk := range chunks {
+		if !options.stripOuterArray {
+			documents = append(documents, json.RawMessage(bytes.TrimSpace(chunk)))
+			continue
+		}
+
+		var array []json.RawMessage
+		if err := json.Unmarshal(chunk, &array); err != nil {
+			if options.readJsonByLine {
+				// An invalid line is a filtered row.
+				documents = append(documents, json.RawMessage(bytes.TrimSpace(chunk)))
+				continue
+			}
+
+			return nil, fmt.Errorf("JSON data is not an array while strip_outer_array is true: %v", err)
+		}
+
+		documents = append(documents, array...)
+	}
+
+	return documents, nil
+}
+
+// jsonFields maps the fields of a JSON object to the source columns, by jsonpaths if set or else by name.
+func jsonFields(
+	schema loader.TableSchema,
+	options loadOptions,
+	document json.RawMessage,
+) (map[string]*string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return nil, errors.New("Parse json data for JsonDoc failed. the input is not a valid JSON object")
+	}
+
+	columns := options.columns
+	if columns == nil {
+		columns = schema.ColumnNames()
+	}
+
+	fields := map[string]*string{}
+
+	if options.jsonPaths != nil {
+		for i, path := range options.jsonPaths {
+			if i >= len(columns) {
+				break
+			}
+
+			if value, ok := jsonPath(object, path); ok {
+				fields[strings.ToLower(columns[i])] = dorisconv.JsonText(value)
+			}
+		}
+
+		return fields, nil
+	}
+
+	for key, value := range object {
+		for _, column := range columns {
+			if strings.EqualFold(key, column) {
+				fields[strings.ToLower(column)] = dorisconv.JsonText(value)
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// jsonPath returns the value of a path like $.a.b in the object.
+func jsonPath(object map[string]any, path string) (any, bool) {
+	var value any = object
+
+	for _, key := range strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "$"), "."), ".") {
+		if key == "" {
+			continue
+		}
+
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+
+		if value, ok = fields[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// convertRow converts the fields of a row to the table columns. Columns missing from the fields are NULL.
+func convertRow(schema loader.TableSchema, fields map[string]*string, strict bool) (Row, error) {
+	row := Row{}
+
+	for _, column := range schema.Columns {
+		value := fields[strings.ToLower(column.Name)]
+
+		var converted any
+		if value != nil {
+			text, err := dorisconv.Convert(dorisconv.Type{
+				Name:      column.Type,
+				Length:    column.Length,
+				Precision: column.Precision,
+				Scale:     column.Scale,
+			}, *value)
+
+			var lengthErr *dorisconv.LengthError
+			switch {
+			case errors.As(err, &lengthErr):
+				return nil, fmt.Errorf("column_name[%s], the length of input is too long than schema. first 32 bytes of input str: [%s] schema length: %d; actual length: %d", column.Name, truncate(*value, 32), column.Length, len(*value))
+			case err != nil && strict:
+				return nil, fmt.Errorf("column(%s) value is incorrect while strict mode is true, src value is %s", column.Name, *value)
+			case err == nil:
+				converted = text
+			}
+		}
+
+		if converted == nil && !column.Nullable {
+			return nil, fmt.Errorf("column(%s) values is null while columns is not nullable", column.Name)
+		}
+
+		row[column.Name] = converted
+	}
+
+	return row, nil
+}
+
+// boolHeader parses a boolean header, false if absent.
+func boolHeader(header http.Header, key string) (bool, error) {
+	value := header.Get(key)
+	if value == "" {
+		return false, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %s", key, value)
+	}
+
+	return parsed, nil
+}
+
+// unescape decodes separators given in hexadecimal like \x01, as Doris accepts them.
+func unescape(value string) string {
+	if !strings.HasPrefix(value, `\x`) {
+		return value
+	}
+
+	decoded, err := hex.DecodeString(value[2:])
+	if err != nil {
+		return value
+	}
+
+	return string(decoded)
+}
+
+// truncate returns the first n bytes of the value.
+func truncate(value string, n int) string {
+	if len(value) <= n {
+		return value
+	}
+
+	return value[:n]
+}
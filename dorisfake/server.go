@@ -0,0 +1,642 @@
+// Package dorisfake is an in-process fake of a Doris cluster for tests. Its FE and BE nodes are httptest servers serving the stream load API with the 307 redirect, Basic authentication, label deduplication, CSV and JSON parsing against declared table schemas, max_filter_ratio and error log pages, so loaders can be tested without a real cluster.
+package dorisfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/loader"
+)
+
+// Server is a fake Doris cluster of FE and BE nodes sharing the same tables.
+//
+//	server, err := dorisfake.NewServer(dorisfake.WithTable(loader.TableSchema{
+//		Database: "test_db",
+//		Table:    "users",
+//		Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true}},
+//	}))
+//	defer server.Close()
+//
+//	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"))
+type Server struct {
+	Username string // Username accepted by the nodes (default: root)
+	Password string // Password accepted by the nodes (default: "")
+	FeCount  int    // Number of FE nodes (default: 1)
+	BeCount  int    // Number of BE nodes (default: 1)
+
+	fes []*node
+	bes []*node
+
+	mu        sync.Mutex
+	faults    *FaultPlan
+	tables    map[string]*table
+	labels    map[string]map[string]int
+	loads     []Load
+	errorLogs map[string]string
+	txnId     int
+	nextBe    int
+}
+
+// Row is a row stored in a table, mapping the column names to the values Doris parsed from the payload as text. NULL is nil.
+type Row map[string]any
+
+// Load is a stream load request received by a BE node.
+type Load struct {
+	Database     string                  // Database name
+	Table        string                  // Table name
+	BeNode       string                  // BE node receiving the request
+	Header       http.Header             // Request header
+	Payload      []byte                  // Request body
+	Result       loader.StreamLoadResult // Stream load result sent back
+	FilteredRows []loader.ErrorRow       // Rows filtered by the load
+}
+
+// node is an FE or BE node, which refuses connections while offline.
+type node struct {
+	*httptest.Server
+	listener *offlineListener
+	offline  atomic.Bool
+}
+
+// startNode starts a node serving the handler.
+func startNode(handler http.Handler) *node {
+	n := &node{Server: httptest.NewUnstartedServer(handler)}
+	n.listener = newOfflineListener(n.Listener)
+	n.Listener = n.listener
+	n.Start()
+
+	return n
+}
+
+// table is a declared table and its committed rows.
+type table struct {
+	schema loader.TableSchema
+	rows   []Row
+}
+
+type Option func(*Server) error
+
+// NewServer starts the FE and BE nodes of a fake cluster. Call Close to stop them.
+func NewServer(options ...Option) (*Server, error) {
+	server := Server{
+		Username:  "root",
+		FeCount:   1,
+		BeCount:   1,
+		tables:    map[string]*table{},
+		labels:    map[string]map[string]int{},
+		errorLogs: map[string]string{},
+	}
+
+	for _, option := range options {
+		if err := option(&server); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < server.BeCount; i++ {
+		server.bes = append(server.bes, startNode(server.beHandler()))
+	}
+
+	for i := 0; i < server.FeCount; i++ {
+		server.fes = append(server.fes, startNode(server.feHandler()))
+	}
+
+	return &server, nil
+}
+
+// Close stops the nodes.
+func (s *Server) Close() {
+	for _, n := range append(s.fes, s.bes...) {
+		n.Close()
+	}
+}
+
+// FeNodes returns the addresses of the FE nodes, which can be passed to loader.NewStreamLoader.
+func (s *Server) FeNodes() []string {
+	return addrs(s.fes)
+}
+
+// BeNodes returns the addresses of the BE nodes, which can be passed to loader.WithBeNodes.
+func (s *Server) BeNodes() []string {
+	return addrs(s.bes)
+}
+
+// SetFaultPlan replaces the fault plan of the nodes. A nil plan removes the faults.
+func (s *Server) SetFaultPlan(plan *FaultPlan) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = plan
+}
+
+// SetOffline takes the node offline or brings it back. An offline node closes its connections and its socket, so that new connections are refused, and FE nodes neither report offline BE nodes as alive nor redirect to them unless all BE nodes are offline. It returns an error if the node cannot listen on its address again.
+func (s *Server) SetOffline(addr string, offline bool) error {
+	for _, n := range append(s.fes, s.bes...) {
+		if hostOf(n) != addr {
+			continue
+		}
+
+		n.offline.Store(offline)
+		if err := n.listener.setOffline(offline); err != nil {
+			return err
+		}
+
+		if offline {
+			n.CloseClientConnections()
+		}
+	}
+
+	return nil
+}
+
+// Rows returns the rows committed to the table in load order.
+func (s *Server) Rows(database string, tableName string) []Row {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tables[tableKey(database, tableName)]
+	if !ok {
+		return nil
+	}
+
+	return append([]Row(nil), t.rows...)
+}
+
+// Loads returns the stream load requests received by the BE nodes in order, including the failed ones.
+func (s *Server) Loads() []Load {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Load(nil), s.loads...)
+}
+
+// Reset removes the rows, labels, loads and error logs while keeping the declared tables.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tables {
+		t.rows = nil
+	}
+	s.labels = map[string]map[string]int{}
+	s.loads = nil
+	s.errorLogs = map[string]string{}
+}
+
+// feHandler serves the FE APIs used by the loader: stream load, which is redirected to a BE node, health, backends and table schema.
+func (s *Server) feHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("PUT /api/{db}/{table}/_stream_load", func(w http.ResponseWriter, r *http.Request) {
+		if s.nextFault(roleFe, r.Host).inject(w, r) {
+			return
+		}
+
+		// Reading the body answers "Expect: 100-continue" right away, so the client doesn't wait before following the redirect.
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		location := url.URL{
+			Scheme:   "http",
+			User:     url.UserPassword(s.Username, s.Password),
+			Host:     s.selectBe(),
+			Path:     r.URL.Path,
+			RawQuery: r.URL.RawQuery,
+		}
+
+		w.Header().Set("Location", location.String())
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+
+	mux.HandleFunc("GET /api/health", func(w http.ResponseWriter, r *http.Request) {
+		online := 0
+		for _, be := range s.bes {
+			if !be.offline.Load() {
+				online++
+			}
+		}
+
+		writeJson(w, map[string]any{
+			"code": 0,
+			"msg":  "success",
+			"data": map[string]any{"online_backend_num": online, "total_backend_num": len(s.bes)},
+		})
+	})
+
+	mux.HandleFunc("GET /api/backends", func(w http.ResponseWriter, r *http.Request) {
+		backends := []map[string]any{}
+		for _, be := range s.bes {
+			host, port, _ := strings.Cut(hostOf(be), ":")
+			httpPort, _ := strconv.Atoi(port)
+			backends = append(backends, map[string]any{"ip": host, "http_port": httpPort, "is_alive": !be.offline.Load()})
+		}
+
+		writeJson(w, map[string]any{
+			"code": 0,
+			"msg":  "success",
+			"data": map[string]any{"backends": backends},
+		})
+	})
+
+	mux.HandleFunc("GET /api/{db}/{table}/_schema", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		t, ok := s.tables[tableKey(r.PathValue("db"), r.PathValue("table"))]
+		s.mu.Unlock()
+
+		if !ok {
+			writeJson(w, map[string]any{"code": 1, "msg": fmt.Sprintf("Unknown table '%s'", r.PathValue("table"))})
+			return
+		}
+
+		writeJson(w, map[string]any{
+			"code": 0,
+			"msg":  "success",
+			"data": schemaData(t.schema),
+		})
+	})
+
+	return s.authenticate(mux)
+}
+
+// beHandler serves the BE APIs: stream load and the error log pages of ErrorURL.
+func (s *Server) beHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.Handle("PUT /api/{db}/{table}/_stream_load", s.authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fault := s.nextFault(roleBe, r.Host)
+		if fault.inject(w, r) {
+			return
+		}
+
+		payload, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		result := s.streamLoad(r.PathValue("db"), r.PathValue("table"), r.Host, r.Header, payload, fault)
+		if fault.kind == faultLoseResponse {
+			dropConnection(w)
+			return
+		}
+
+		writeJson(w, result)
+	})))
+
+	mux.HandleFunc("GET /api/_load_error_log", func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		content, ok := s.errorLogs[r.URL.Query().Get("file")]
+		s.mu.Unlock()
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = io.WriteString(w, content)
+	})
+
+	return mux
+}
+
+// authenticate rejects the requests without the Basic authentication of the server.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != s.Username || password != s.Password {
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJson(w, map[string]any{"code": 401, "msg": "Unauthorized"})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// streamLoad loads the payload to the table and records the load. The fault may turn the status of a committed load into Publish Timeout.
+func (s *Server) streamLoad(
+	database string,
+	tableName string,
+	beNode string,
+	header http.Header,
+	payload []byte,
+	fault Fault,
+) loader.StreamLoadResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, filtered := s.load(database, tableName, beNode, header, payload)
+	if fault.kind == faultPublishTimeout && result.IsSuccess() {
+		result.Status = "Publish Timeout"
+		result.Message = "transaction commit successfully, BUT data will be visible later"
+	}
+
+	s.loads = append(s.loads, Load{
+		Database:     database,
+		Table:        tableName,
+		BeNode:       beNode,
+		Header:       header.Clone(),
+		Payload:      payload,
+		Result:       result,
+		FilteredRows: filtered,
+	})
+
+	return result
+}
+
+// load parses the payload against the table schema and commits the rows unless too many of them are filtered. The caller must hold the lock.
+func (s *Server) load(
+	database string,
+	tableName string,
+	beNode string,
+	header http.Header,
+	payload []byte,
+) (loader.StreamLoadResult, []loader.ErrorRow) {
+	start := time.Now()
+
+	result := loader.StreamLoadResult{
+		Label:          header.Get("label"),
+		TwoPhaseCommit: "false",
+		Status:         "Fail",
+		LoadBytes:      len(payload),
+	}
+	if result.Label == "" {
+		result.Label = newLabel()
+	}
+
+	t, ok := s.tables[tableKey(database, tableName)]
+	if !ok {
+		result.Message = fmt.Sprintf("[ANALYSIS_ERROR]TStatus: errCode = 2, detailMessage = Unknown table '%s'", tableName)
+		return result, nil
+	}
+
+	if txnId, ok := s.labels[database][result.Label]; ok {
+		result.Status = "Label Already Exists"
+		result.ExistingJobStatus = "FINISHED"
+		result.Message = fmt.Sprintf("Label [%s] has already been used, relate to txn [%d]", result.Label, txnId)
+		return result, nil
+	}
+
+	s.txnId++
+	result.TxnId = s.txnId
+
+	options, err := parseOptions(header)
+	if err != nil {
+		result.Message = fmt.Sprintf("[INVALID_ARGUMENT]%s", err)
+		return result, nil
+	}
+
+	rows, filtered, total, err := parseRows(t.schema, options, payload)
+	if err != nil {
+		result.Message = fmt.Sprintf("[INVALID_ARGUMENT]%s", err)
+		return result, nil
+	}
+
+	result.NumberTotalRows = total
+	result.NumberLoadedRows = len(rows)
+	result.NumberFilteredRows = len(filtered)
+
+	if len(filtered) > 0 {
+		result.ErrorURL = s.writeErrorLog(beNode, result.TxnId, filtered)
+	}
+
+	result.LoadTimeMs = int(time.Since(start).Milliseconds())
+
+	if total > 0 && float64(len(filtered))/float64(total) > options.maxFilterRatio {
+		result.Message = "[DATA_QUALITY_ERROR]too many filtered rows"
+		return result, filtered
+	}
+
+	t.rows = append(t.rows, rows...)
+	if s.labels[database] == nil {
+		s.labels[database] = map[string]int{}
+	}
+	s.labels[database][result.Label] = result.TxnId
+
+	result.Status = "Success"
+	result.Message = "OK"
+
+	return result, filtered
+}
+
+// writeErrorLog stores the filtered rows in the format of Doris error logs and returns their URL. The caller must hold the lock.
+func (s *Server) writeErrorLog(beNode string, txnId int, filtered []loader.ErrorRow) string {
+	var content strings.Builder
+	for _, row := range filtered {
+		fmt.Fprintf(&content, "Reason: %s. src line [%s]; \n", row.Reason, row.RawRow)
+	}
+
+	file := fmt.Sprintf("__shard_%d/error_log_insert_stmt_%s", txnId%10, randomHex(8))
+	s.errorLogs[file] = content.String()
+
+	return fmt.Sprintf("http://%s/api/_load_error_log?file=%s", beNode, url.QueryEscape(file))
+}
+
+// selectBe returns the BE node of the next redirect in round-robin order, skipping the offline nodes unless all of them are.
+func (s *Server) selectBe() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for range s.bes {
+		be := s.bes[s.nextBe%len(s.bes)]
+		s.nextBe++
+
+		if !be.offline.Load() {
+			return hostOf(be)
+		}
+	}
+
+	be := s.bes[s.nextBe%len(s.bes)]
+	s.nextBe++
+
+	return hostOf(be)
+}
+
+// nextFault takes the fault of a stream load request received by the node.
+func (s *Server) nextFault(role string, addr string) Fault {
+	s.mu.Lock()
+	plan := s.faults
+	s.mu.Unlock()
+
+	return plan.next(role, addr)
+}
+
+// schemaData returns the data of the FE table schema API.
+func schemaData(schema loader.TableSchema) map[string]any {
+	properties := []map[string]any{}
+	for _, column := range schema.Columns {
+		nullable := "No"
+		if column.Nullable {
+			nullable = "Yes"
+		}
+
+		key := "No"
+		if column.Key {
+			key = "Yes"
+		}
+
+		property := map[string]any{
+			"name":             column.Name,
+			"type":             column.Type,
+			"is_key":           key,
+			"aggregation_type": column.AggregationType,
+			"comment":          column.Comment,
+			"is_nullable":      nullable,
+		}
+
+		if column.Length > 0 {
+			property["type"] = fmt.Sprintf("%s(%d)", column.Type, column.Length)
+		}
+
+		if column.Precision > 0 {
+			property["precision"] = strconv.Itoa(column.Precision)
+			property["scale"] = strconv.Itoa(column.Scale)
+		}
+
+		properties = append(properties, property)
+	}
+
+	keysType := schema.KeysType
+	if keysType == "" {
+		keysType = "DUP_KEYS"
+	}
+
+	return map[string]any{
+		"properties": properties,
+		"keysType":   keysType,
+		"status":     http.StatusOK,
+	}
+}
+
+// writeJson writes the value as a JSON response body.
+func writeJson(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// tableKey returns the key of a table in Server.tables.
+func tableKey(database string, tableName string) string {
+	return database + "." + tableName
+}
+
+// addrs returns the addresses of the nodes.
+func addrs(nodes []*node) []string {
+	addrs := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		addrs = append(addrs, hostOf(node))
+	}
+
+	return addrs
+}
+
+// hostOf returns the address of the node without scheme.
+func hostOf(n *node) string {
+	return strings.TrimPrefix(n.URL, "http://")
+}
+
+// newLabel generates a label like Doris does for loads without one.
+func newLabel() string {
+	id := randomHex(16)
+
+	return fmt.Sprintf("%s-%s-%s-%s-%s", id[0:8], id[8:12], id[12:16], id[16:20], id[20:32])
+}
+
+// randomHex returns n random bytes encoded in hexadecimal.
+func randomHex(n int) string {
+	data := make([]byte, n)
+	_, _ = rand.Read(data)
+
+	return hex.EncodeToString(data)
+}
+
+// WithCredentials sets the username and password accepted by the nodes. It'll return an error if there have any credentials set before.
+func WithCredentials(username string, password string) Option {
+	return func(server *Server) error {
+		if username == "" {
+			return loader.ErrZeroValueOption("Username")
+		}
+
+		// root without password is the default value
+		if (server.Username != "root" || server.Password != "") && (server.Username != username || server.Password != password) {
+			return loader.ErrAmbiguousOption("Credentials")
+		}
+
+		server.Username = username
+		server.Password = password
+
+		return nil
+	}
+}
+
+// WithTable declares a table loads can be sent to. The columns are parsed in the order of the schema unless the load sets the columns header. It'll return an error if the table was declared before.
+func WithTable(schema loader.TableSchema) Option {
+	return func(server *Server) error {
+		if schema.Database == "" {
+			return loader.ErrMissingRequiredValue("Database")
+		}
+
+		if schema.Table == "" {
+			return loader.ErrMissingRequiredValue("Table")
+		}
+
+		if len(schema.Columns) == 0 {
+			return loader.ErrMissingRequiredValue("Columns")
+		}
+
+		key := tableKey(schema.Database, schema.Table)
+		if _, ok := server.tables[key]; ok {
+			return loader.ErrAmbiguousOption("Table")
+		}
+
+		server.tables[key] = &table{schema: schema}
+
+		return nil
+	}
+}
+
+// WithNodes sets the number of FE and BE nodes. FE nodes redirect the stream loads to the BE nodes in round-robin order. It'll return an error if there have any numbers set before.
+func WithNodes(feCount int, beCount int) Option {
+	return func(server *Server) error {
+		if feCount <= 0 || beCount <= 0 {
+			return loader.ErrUnsupportValue("Nodes")
+		}
+
+		// 1 FE and 1 BE is the default value
+		if (server.FeCount != 1 || server.BeCount != 1) && (server.FeCount != feCount || server.BeCount != beCount) {
+			return loader.ErrAmbiguousOption("Nodes")
+		}
+
+		server.FeCount = feCount
+		server.BeCount = beCount
+
+		return nil
+	}
+}
+
+// WithFaultPlan sets the fault plan of the nodes. It'll return an error if there has any plan set before.
+func WithFaultPlan(plan *FaultPlan) Option {
+	return func(server *Server) error {
+		if plan == nil {
+			return loader.ErrZeroValueOption("FaultPlan")
+		}
+
+		if server.faults != nil && server.faults != plan {
+			return loader.ErrAmbiguousOption("FaultPlan")
+		}
+
+		server.faults = plan
+
+		return nil
+	}
+}
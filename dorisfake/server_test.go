@@ -0,0 +1,375 @@
+package dorisfake_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var users = loader.TableSchema{
+	Database: "test_db",
+	Table:    "users",
+	Columns: []loader.Column{
+		{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true},
+		{Name: "age", Type: "INT", Nullable: true},
+	},
+}
+
+func newServer(t *testing.T, options ...dorisfake.Option) *dorisfake.Server {
+	t.Helper()
+
+	server, err := dorisfake.NewServer(append([]dorisfake.Option{dorisfake.WithTable(users)}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestStreamLoad(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Filename        string
+		Options         []loader.StreamLoaderOption
+		ExpectSuccess   bool
+		ExpectRows      []dorisfake.Row
+		ExpectFiltered  int
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "stream load a json file",
+			Filename:        "../manifest/test/users.json",
+			ExpectSuccess:   true,
+			ExpectRows:      []dorisfake.Row{{"name": "John Doe", "age": "30"}},
+		},
+		{
+			TestDescription: "stream load a csv file with columns",
+			Filename:        "../manifest/test/users_no_header.csv",
+			Options: []loader.StreamLoaderOption{
+				loader.WithLoadFormat(loadformat.Csv),
+				loader.WithColumns([]string{"name", "age"}),
+				loader.WithColumnSeparator(","),
+			},
+			ExpectSuccess: true,
+			ExpectRows:    []dorisfake.Row{{"name": "John Chen", "age": "30"}},
+		},
+		{
+			TestDescription: "stream load a csv file with a header line",
+			Filename:        "../manifest/test/users_with_header.csv",
+			Options: []loader.StreamLoaderOption{
+				loader.WithLoadFormat(loadformat.CsvWithNames),
+				loader.WithColumnSeparator(","),
+			},
+			ExpectSuccess: true,
+			ExpectRows:    []dorisfake.Row{{"name": "John Chen", "age": "30"}},
+		},
+		{
+			TestDescription: "stream load a csv file with custom column separator",
+			Filename:        "../manifest/test/users_pipe_separator.csv",
+			Options: []loader.StreamLoaderOption{
+				loader.WithLoadFormat(loadformat.Csv),
+				loader.WithColumnSeparator("|"),
+			},
+			ExpectSuccess: true,
+			ExpectRows:    []dorisfake.Row{{"name": "John Chen", "age": "30"}},
+		},
+		{
+			TestDescription: "a filtered row should fail the load with max filter ratio 0",
+			Filename:        "../manifest/test/users_wrong_data.json",
+			Options:         []loader.StreamLoaderOption{loader.WithMaxFilterRatio(0)},
+			ExpectFiltered:  1,
+		},
+		{
+			TestDescription: "a filtered row should be skipped with max filter ratio 1",
+			Filename:        "../manifest/test/users_wrong_data.json",
+			Options:         []loader.StreamLoaderOption{loader.WithMaxFilterRatio(1)},
+			ExpectSuccess:   true,
+			ExpectRows:      []dorisfake.Row{{"name": "Kimi", "age": "20"}},
+			ExpectFiltered:  1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		server := newServer(t)
+
+		ld, err := loader.NewStreamLoader(
+			server.FeNodes(),
+			"test_db",
+			"users",
+			append([]loader.StreamLoaderOption{loader.WithUsername("root")}, tc.Options...)...,
+		)
+		assert.NoError(t, err)
+
+		result, err := ld.LoadFile(context.Background(), tc.Filename)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ExpectSuccess, result.IsSuccess(), result.Message)
+		assert.Equal(t, tc.ExpectFiltered, result.NumberFilteredRows)
+		assert.Equal(t, tc.ExpectRows, server.Rows("test_db", "users"))
+	}
+}
+
+func TestStreamLoadCannotLoadBySameLabelTwice(t *testing.T) {
+	t.Log("the second load with the same label should be rejected and not stored")
+
+	server := newServer(t)
+
+	ld, err := loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithLabel("label_twice"),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, "label_twice", result.Label)
+
+	result, err = ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.NoError(t, err)
+	assert.False(t, result.IsSuccess())
+	assert.True(t, result.IsAlreadyLoaded())
+	assert.Len(t, server.Rows("test_db", "users"), 1)
+	assert.Len(t, server.Loads(), 2)
+
+	t.Log("a generated label should be returned for a load without one")
+	ld, err = loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"))
+	assert.NoError(t, err)
+
+	result, err = ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Len(t, result.Label, 36)
+}
+
+func TestStreamLoadAuthentication(t *testing.T) {
+	t.Log("a load with wrong credentials should be unauthorized")
+
+	server := newServer(t, dorisfake.WithCredentials("loader", "s3cret"))
+
+	ld, err := loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("loader"),
+		loader.WithPassword("wrong"),
+		loader.WithMaxRetry(1),
+	)
+	assert.NoError(t, err)
+
+	_, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.ErrorIs(t, err, loader.ErrUnauthorized)
+	assert.Empty(t, server.Loads())
+
+	ld, err = loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("loader"),
+		loader.WithPassword("s3cret"),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+}
+
+func TestStreamLoadSchema(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Payload         string
+		Header          map[string]any
+		ExpectRows      []dorisfake.Row
+		ExpectReasons   []string
+	}
+
+	schema := loader.TableSchema{
+		Database: "test_db",
+		Table:    "accounts",
+		Columns: []loader.Column{
+			{Name: "id", Type: "BIGINT"},
+			{Name: "code", Type: "CHAR", Length: 3, Nullable: true},
+			{Name: "balance", Type: "DECIMAL64", Precision: 5, Scale: 2, Nullable: true},
+			{Name: "opened", Type: "DATE", Nullable: true},
+		},
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "invalid values should become NULL in non-strict mode",
+			Payload:         "1,abc,12.5,2024-01-02\n2,xyz,99999,not a date\n",
+			Header:          map[string]any{"max_filter_ratio": 1},
+			ExpectRows: []dorisfake.Row{
+				{"id": "1", "code": "abc", "balance": "12.5", "opened": "2024-01-02"},
+				{"id": "2", "code": "xyz", "balance": nil, "opened": nil},
+			},
+		},
+		{
+			TestDescription: "invalid values, NULL in NOT NULL columns, long strings and wrong column counts should be filtered in strict mode",
+			Payload:         "1,abc,12.5,2024-01-02\n2,xyz,99999,2024-01-02\n\\N,abc,1,2024-01-02\n3,abcd,1,2024-01-02\n4,abc\n",
+			Header:          map[string]any{"max_filter_ratio": 1, "strict_mode": true},
+			ExpectRows: []dorisfake.Row{
+				{"id": "1", "code": "abc", "balance": "12.5", "opened": "2024-01-02"},
+			},
+			ExpectReasons: []string{
+				"column(balance) value is incorrect while strict mode is true",
+				"column(id) values is null while columns is not nullable",
+				"the length of input is too long than schema",
+				"actual column number in csv file is less than schema column number",
+			},
+		},
+		{
+			TestDescription: "columns should map the source fields to the table columns",
+			Payload:         "2024-01-02,7\n",
+			Header:          map[string]any{"columns": "opened,id"},
+			ExpectRows: []dorisfake.Row{
+				{"id": "7", "code": nil, "balance": nil, "opened": "2024-01-02"},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		server := newServer(t, dorisfake.WithTable(schema))
+
+		ld, err := loader.NewStreamLoader(
+			server.FeNodes(),
+			"test_db",
+			"accounts",
+			loader.WithUsername("root"),
+			loader.WithLoadFormat(loadformat.Csv),
+			loader.WithColumnSeparator(","),
+			loader.WithHeader(tc.Header),
+		)
+		assert.NoError(t, err)
+
+		result, err := ld.LoadReader(context.Background(), strings.NewReader(tc.Payload), "")
+		assert.NoError(t, err)
+		assert.True(t, result.IsSuccess(), result.Message)
+		assert.Equal(t, tc.ExpectRows, server.Rows("test_db", "accounts"))
+
+		rows, err := ld.FetchErrorRows(context.Background(), result)
+		assert.NoError(t, err)
+		assert.Len(t, rows, len(tc.ExpectReasons))
+		for i := range rows {
+			if i < len(tc.ExpectReasons) {
+				assert.Contains(t, rows[i].Reason, tc.ExpectReasons[i])
+			}
+		}
+
+		loads := server.Loads()
+		if assert.Len(t, loads, 1) {
+			assert.Equal(t, rows, loads[0].FilteredRows)
+			assert.Equal(t, tc.Payload, string(loads[0].Payload))
+		}
+	}
+}
+
+func TestStreamLoadErrorURL(t *testing.T) {
+	t.Log("the rows filtered by a failed load should be served by the error log page and delivered to the dead letter sink")
+
+	server := newServer(t)
+
+	var deadLetters []loader.ErrorRow
+	ld, err := loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithDeadLetterSink(loader.DeadLetterFunc(func(ctx context.Context, result *loader.StreamLoadResult, rows []loader.ErrorRow) error {
+			deadLetters = append(deadLetters, rows...)
+			return nil
+		})),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFile(context.Background(), "../manifest/test/users_wrong_data.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "Fail", result.Status)
+	assert.Contains(t, result.Message, "too many filtered rows")
+	assert.Empty(t, server.Rows("test_db", "users"))
+
+	if assert.Len(t, deadLetters, 1) {
+		assert.Equal(t, `{"name": 10, "age": "Kimi"`, deadLetters[0].RawRow)
+		assert.Contains(t, deadLetters[0].Reason, "Parse json data")
+	}
+
+	t.Log("the label of a failed load can be used again")
+	ld, err = loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"), loader.WithLabel(result.Label))
+	assert.NoError(t, err)
+
+	result, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "Kimi", "age": 20}`), "")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+}
+
+func TestNodes(t *testing.T) {
+	t.Log("FE nodes should redirect to the BE nodes in turn, and serve the schema and backends APIs")
+
+	server := newServer(t, dorisfake.WithNodes(2, 3))
+	assert.Len(t, server.FeNodes(), 2)
+	assert.Len(t, server.BeNodes(), 3)
+
+	ld, err := loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithBeDiscovery(time.Hour),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = ld.Close() })
+
+	schema, err := ld.Schema(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, users.Columns, schema.Columns)
+
+	for i := 0; i < 3; i++ {
+		_, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe", "age": 30}`), "")
+		assert.NoError(t, err)
+	}
+
+	reached := map[string]bool{}
+	for _, load := range server.Loads() {
+		reached[load.BeNode] = true
+	}
+	assert.Len(t, reached, 3)
+
+	t.Log("Reset should remove the rows and loads")
+	server.Reset()
+	assert.Empty(t, server.Rows("test_db", "users"))
+	assert.Empty(t, server.Loads())
+}
+
+func TestNewServer(t *testing.T) {
+	t.Log("invalid or conflicting options should be rejected")
+
+	_, err := dorisfake.NewServer(dorisfake.WithTable(users), dorisfake.WithTable(users))
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("Table").Error())
+
+	_, err = dorisfake.NewServer(dorisfake.WithCredentials("a", "b"), dorisfake.WithCredentials("c", "d"))
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("Credentials").Error())
+
+	_, err = dorisfake.NewServer(dorisfake.WithNodes(0, 1))
+	assert.EqualError(t, err, loader.ErrUnsupportValue("Nodes").Error())
+
+	_, err = dorisfake.NewServer(dorisfake.WithFaultPlan(nil))
+	assert.EqualError(t, err, loader.ErrZeroValueOption("FaultPlan").Error())
+
+	_, err = dorisfake.NewServer(dorisfake.WithFaultPlan(dorisfake.NewFaultPlan()), dorisfake.WithFaultPlan(dorisfake.NewFaultPlan()))
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("FaultPlan").Error())
+}
@@ -0,0 +1,7 @@
+package dorisreplay
+
+import "errors"
+
+var (
+	ErrUnexpectedRequest = errors.New("unexpected request")
+)
@@ -0,0 +1,99 @@
+package dorisreplay
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the values of the redacted headers in golden files.
+const redacted = "REDACTED"
+
+// Fixture is the content of a golden file: the interactions in the order they were recorded.
+type Fixture struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+// Interaction is a request and its response, or the error of the transport if the request got no response.
+type Interaction struct {
+	Request  Request   `yaml:"request"`
+	Response *Response `yaml:"response,omitempty"`
+	Error    string    `yaml:"error,omitempty"`
+}
+
+// Request is a recorded request. The credentials are redacted from its URL and headers.
+type Request struct {
+	Method string      `yaml:"method"`
+	URL    string      `yaml:"url"`
+	Header http.Header `yaml:"header,omitempty"`
+	Body   string      `yaml:"body,omitempty"`
+}
+
+// Response is a recorded response. The credentials are redacted from its headers.
+type Response struct {
+	StatusCode int         `yaml:"status_code"`
+	Header     http.Header `yaml:"header,omitempty"`
+	Body       string      `yaml:"body,omitempty"`
+}
+
+// readFixture reads the golden file.
+func readFixture(path string) (*Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fixture Fixture
+	if err := yaml.Unmarshal(data, &fixture); err != nil {
+		return nil, err
+	}
+
+	return &fixture, nil
+}
+
+// writeFixture writes the golden file, creating its directory if needed.
+func writeFixture(path string, fixture *Fixture) error {
+	data, err := yaml.Marshal(fixture)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// redactURL removes the user info of the URL, which carries the credentials of the redirects from FE to BE nodes.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+
+	return redacted.String()
+}
+
+// redactHeader copies the header, replacing the values of the redacted headers and removing the user info of the Location header.
+func redactHeader(header http.Header, names []string) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+
+	header = header.Clone()
+	for _, name := range names {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			header.Set(name, redacted)
+		}
+	}
+
+	if location := header.Get("Location"); location != "" {
+		if u, err := url.Parse(location); err == nil {
+			header.Set("Location", redactURL(u))
+		}
+	}
+
+	return header
+}
@@ -0,0 +1,158 @@
+package dorisreplay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/raaaaaaaay86/doris-loader/loader"
+)
+
+// Recorder is a transport recording the requests sent through it and their responses, to be saved in a golden file and served back by a Replayer. It's set on a loader with loader.WithTransport.
+type Recorder struct {
+	Path            string            // Path of the golden file
+	Base            http.RoundTripper // Transport sending the requests (default: http.DefaultTransport)
+	RedactedHeaders []string          // Headers whose values are redacted (default: Authorization, Proxy-Authorization, Cookie and Set-Cookie)
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+type RecorderOption func(*Recorder) error
+
+// NewRecorder creates a recorder saving the interactions in the golden file at the path.
+func NewRecorder(
+	path string,
+	options ...RecorderOption,
+) (*Recorder, error) {
+	if path == "" {
+		return nil, loader.ErrMissingRequiredValue("Path")
+	}
+
+	recorder := Recorder{
+		Path:            path,
+		Base:            http.DefaultTransport,
+		RedactedHeaders: []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"},
+	}
+
+	for _, option := range options {
+		if err := option(&recorder); err != nil {
+			return nil, err
+		}
+	}
+
+	return &recorder, nil
+}
+
+// RoundTrip sends the request with the Base transport and records it with its response. The bodies are read entirely, so that they're recorded as sent and received.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    redactURL(req.URL),
+			Header: redactHeader(req.Header, r.RedactedHeaders),
+			Body:   string(body),
+		},
+	}
+
+	req = req.Clone(req.Context())
+	if len(body) > 0 {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	} else if req.Body != nil {
+		req.Body = http.NoBody
+	}
+
+	res, err := r.Base.RoundTrip(req)
+	if err != nil {
+		interaction.Error = err.Error()
+		r.record(interaction)
+		return nil, err
+	}
+
+	resBody, err := readBody(res.Body)
+	if err != nil {
+		interaction.Error = err.Error()
+		r.record(interaction)
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	interaction.Response = &Response{
+		StatusCode: res.StatusCode,
+		Header:     redactHeader(res.Header, r.RedactedHeaders),
+		Body:       string(resBody),
+	}
+	r.record(interaction)
+
+	return res, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded interactions to the golden file, replacing its content.
+func (r *Recorder) Save() error {
+	return writeFixture(r.Path, &Fixture{Interactions: r.Interactions()})
+}
+
+// record appends the interaction.
+func (r *Recorder) record(interaction Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.interactions = append(r.interactions, interaction)
+}
+
+// readBody reads and closes the body, which may be nil.
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+
+	return io.ReadAll(body)
+}
+
+// WithBase sets the transport sending the recorded requests. It'll return an error if there has any transport set before.
+func WithBase(base http.RoundTripper) RecorderOption {
+	return func(recorder *Recorder) error {
+		if base == nil {
+			return loader.ErrZeroValueOption("Base")
+		}
+
+		// http.DefaultTransport is the default value
+		if recorder.Base != http.DefaultTransport && recorder.Base != base {
+			return loader.ErrAmbiguousOption("Base")
+		}
+
+		recorder.Base = base
+
+		return nil
+	}
+}
+
+// WithRedactedHeaders redacts the values of the headers in addition to the default ones, e.g. a header carrying a token.
+func WithRedactedHeaders(names ...string) RecorderOption {
+	return func(recorder *Recorder) error {
+		for _, name := range names {
+			if name == "" {
+				return loader.ErrZeroValueOption("RedactedHeaders")
+			}
+		}
+
+		recorder.RedactedHeaders = append(recorder.RedactedHeaders, names...)
+
+		return nil
+	}
+}
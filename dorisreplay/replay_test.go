@@ -0,0 +1,141 @@
+package dorisreplay_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/dorisreplay"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var users = loader.TableSchema{
+	Database: "test_db",
+	Table:    "users",
+	Columns: []loader.Column{
+		{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true},
+		{Name: "age", Type: "INT", Nullable: true},
+	},
+}
+
+const payload = `{"name": "John Doe", "age": 30}`
+
+func newLoader(t *testing.T, feNodes []string, transport http.RoundTripper) *loader.StreamLoader {
+	t.Helper()
+
+	ld, err := loader.NewStreamLoader(
+		feNodes,
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithPassword("secret"),
+		loader.WithLabel("replay_label"),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithTransport(transport),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ld
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	t.Log("the recorded interactions should be saved with the credentials redacted")
+
+	plan := dorisfake.NewFaultPlan().OnFe(dorisfake.StatusError(http.StatusServiceUnavailable))
+	server, err := dorisfake.NewServer(
+		dorisfake.WithTable(users),
+		dorisfake.WithCredentials("root", "secret"),
+		dorisfake.WithFaultPlan(plan),
+	)
+	assert.NoError(t, err)
+	feNodes := server.FeNodes()
+
+	path := filepath.Join(t.TempDir(), "testdata", "stream_load.yaml")
+	recorder, err := dorisreplay.NewRecorder(path)
+	assert.NoError(t, err)
+
+	recorded, err := newLoader(t, feNodes, recorder).LoadReader(context.Background(), strings.NewReader(payload), "")
+	assert.NoError(t, err)
+	assert.True(t, recorded.IsSuccess())
+	server.Close()
+
+	// 503 from the FE, then the FE redirect and the BE response
+	assert.Len(t, recorder.Interactions(), 3)
+	assert.NoError(t, recorder.Save())
+
+	golden, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(golden), "secret")
+	assert.NotContains(t, string(golden), "Basic ")
+	assert.Contains(t, string(golden), "REDACTED")
+
+	t.Log("the replayed loads should get the recorded results without the cluster")
+
+	replayer, err := dorisreplay.NewReplayer(path)
+	assert.NoError(t, err)
+
+	replayed, err := newLoader(t, feNodes, replayer).LoadReader(context.Background(), strings.NewReader(payload), "")
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+	assert.Zero(t, replayer.Pending())
+
+	t.Log("requests not recorded should fail")
+
+	_, err = newLoader(t, feNodes, replayer).LoadReader(context.Background(), strings.NewReader(payload), "")
+	assert.ErrorIs(t, err, dorisreplay.ErrUnexpectedRequest)
+}
+
+func TestRecordError(t *testing.T) {
+	t.Log("errors of the transport should be recorded and replayed")
+
+	plan := dorisfake.NewFaultPlan().OnBe(dorisfake.DropConnection())
+	server, err := dorisfake.NewServer(dorisfake.WithTable(users), dorisfake.WithCredentials("root", "secret"), dorisfake.WithFaultPlan(plan))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "stream_load.yaml")
+	recorder, err := dorisreplay.NewRecorder(path)
+	assert.NoError(t, err)
+
+	recorded, err := newLoader(t, server.FeNodes(), recorder).LoadReader(context.Background(), strings.NewReader(payload), "")
+	assert.NoError(t, err)
+	assert.NoError(t, recorder.Save())
+
+	interactions := recorder.Interactions()
+	if assert.Len(t, interactions, 4) {
+		assert.Nil(t, interactions[1].Response)
+		assert.NotEmpty(t, interactions[1].Error)
+	}
+
+	replayer, err := dorisreplay.NewReplayer(path)
+	assert.NoError(t, err)
+
+	replayed, err := newLoader(t, server.FeNodes(), replayer).LoadReader(context.Background(), strings.NewReader(payload), "")
+	assert.NoError(t, err)
+	assert.Equal(t, recorded, replayed)
+	assert.Zero(t, replayer.Pending())
+}
+
+func TestNewRecorder(t *testing.T) {
+	t.Log("invalid or conflicting options should be rejected")
+
+	_, err := dorisreplay.NewRecorder("")
+	assert.EqualError(t, err, loader.ErrMissingRequiredValue("Path").Error())
+
+	_, err = dorisreplay.NewRecorder("golden.yaml", dorisreplay.WithBase(nil))
+	assert.EqualError(t, err, loader.ErrZeroValueOption("Base").Error())
+
+	_, err = dorisreplay.NewRecorder("golden.yaml", dorisreplay.WithBase(&http.Transport{}), dorisreplay.WithBase(&http.Transport{}))
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("Base").Error())
+
+	_, err = dorisreplay.NewReplayer(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
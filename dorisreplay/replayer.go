@@ -0,0 +1,105 @@
+package dorisreplay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/raaaaaaaay86/doris-loader/loader"
+)
+
+// Replayer is a transport serving the interactions of a golden file instead of sending the requests. A request is served the first unused interaction with the same method, URL and body, ignoring the credentials; a request matching none fails with ErrUnexpectedRequest. It's set on a loader with loader.WithTransport.
+type Replayer struct {
+	Path string // Path of the golden file
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer creates a replayer serving the interactions of the golden file at the path.
+func NewReplayer(path string) (*Replayer, error) {
+	if path == "" {
+		return nil, loader.ErrMissingRequiredValue("Path")
+	}
+
+	fixture, err := readFixture(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Replayer{
+		Path:         path,
+		interactions: fixture.Interactions,
+		used:         make([]bool, len(fixture.Interactions)),
+	}, nil
+}
+
+// RoundTrip serves the response, or the error, of the interaction matching the request.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction, ok := r.take(req.Method, redactURL(req.URL), string(body))
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s", ErrUnexpectedRequest, req.Method, redactURL(req.URL))
+	}
+
+	if interaction.Response == nil {
+		return nil, errors.New(interaction.Error)
+	}
+
+	header := interaction.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+		ContentLength: int64(len(interaction.Response.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Pending returns the number of interactions not served yet, so that a test can check the loader sent every recorded request.
+func (r *Replayer) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := 0
+	for _, used := range r.used {
+		if !used {
+			pending++
+		}
+	}
+
+	return pending
+}
+
+// take marks the first unused interaction matching the request as used and returns it.
+func (r *Replayer) take(method string, url string, body string) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Request.Method != method || interaction.Request.URL != url || interaction.Request.Body != body {
+			continue
+		}
+
+		r.used[i] = true
+		return interaction, true
+	}
+
+	return Interaction{}, false
+}
@@ -0,0 +1,10 @@
+package beselector
+
+type Enum string
+
+const (
+	RoundRobin    Enum = "round_robin"
+	Random        Enum = "random"
+	LeastInflight Enum = "least_inflight"
+	StickyLabel   Enum = "sticky_label"
+)
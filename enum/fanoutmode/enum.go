@@ -0,0 +1,9 @@
+package fanoutmode
+
+type Enum string
+
+const (
+	All        Enum = "all"
+	Quorum     Enum = "quorum"
+	BestEffort Enum = "best_effort"
+)
@@ -0,0 +1,8 @@
+package queuepolicy
+
+type Enum string
+
+const (
+	Block  Enum = "block"
+	Reject Enum = "reject"
+)
@@ -0,0 +1,179 @@
+// Package dorisconv converts text values to Doris column types the way a stream load does. It's shared by the client-side row validation of loader and the fake cluster of dorisfake, so that both accept the same values.
+package dorisconv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is a column type with its parameters.
+type Type struct {
+	Name      string // Primitive type without parameters (e.g VARCHAR, DECIMAL)
+	Length    int    // Length of CHAR and VARCHAR, 0 if unknown
+	Precision int    // Precision of DECIMAL, 0 if unknown
+	Scale     int    // Scale of DECIMAL
+}
+
+// LengthError is returned for CHAR and VARCHAR values longer than the column, which Doris filters even in non-strict mode.
+type LengthError struct {
+	Length int // Length of the value in bytes
+	Max    int // Length of the column
+}
+
+func (e *LengthError) Error() string {
+	return fmt.Sprintf("length %d exceeds %d", e.Length, e.Max)
+}
+
+var (
+	errSyntax   = errors.New("invalid syntax")
+	errRange    = errors.New("value out of range")
+	errDate     = errors.New("invalid date")
+	errDatetime = errors.New("invalid datetime")
+	errJson     = errors.New("invalid JSON")
+)
+
+var (
+	decimalPattern = regexp.MustCompile(`^[+-]?(\d*)(?:\.(\d*))?$`)
+
+	dateLayouts     = []string{"2006-01-02", "20060102", "2006/01/02"}
+	datetimeLayouts = []string{
+		"2006-01-02 15:04:05.999999999",
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"20060102150405",
+		"2006/01/02 15:04:05",
+		time.RFC3339Nano,
+		"2006-01-02",
+	}
+)
+
+// Convert converts the text to a value of the type, returned as its canonical text. Numbers, booleans, dates and datetimes may be surrounded by spaces. The error tells why the value is incorrect, and is a *LengthError for a string too long. Types without rules accept any value.
+func Convert(typ Type, value string) (string, error) {
+	trimmed := strings.TrimSpace(value)
+	name := strings.ToUpper(typ.Name)
+
+	switch name {
+	case "TINYINT", "SMALLINT", "INT", "BIGINT":
+		bits := map[string]int{"TINYINT": 8, "SMALLINT": 16, "INT": 32, "BIGINT": 64}[name]
+		n, err := strconv.ParseInt(trimmed, 10, bits)
+		if err != nil {
+			return "", numError(err)
+		}
+
+		return strconv.FormatInt(n, 10), nil
+	case "LARGEINT":
+		n, ok := new(big.Int).SetString(trimmed, 10)
+		if !ok {
+			return "", errSyntax
+		}
+
+		limit := new(big.Int).Lsh(big.NewInt(1), 127)
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return "", errRange
+		}
+
+		return n.String(), nil
+	case "FLOAT", "DOUBLE":
+		bits := map[string]int{"FLOAT": 32, "DOUBLE": 64}[name]
+		if _, err := strconv.ParseFloat(trimmed, bits); err != nil {
+			return "", numError(err)
+		}
+
+		return trimmed, nil
+	case "DECIMAL", "DECIMALV2", "DECIMALV3", "DECIMAL32", "DECIMAL64", "DECIMAL128", "DECIMAL128I", "DECIMAL256":
+		match := decimalPattern.FindStringSubmatch(trimmed)
+		if match == nil || match[1]+match[2] == "" {
+			return "", errSyntax
+		}
+
+		if typ.Precision > 0 && len(strings.TrimLeft(match[1], "0")) > typ.Precision-typ.Scale {
+			return "", fmt.Errorf("%w of DECIMAL(%d, %d)", errRange, typ.Precision, typ.Scale)
+		}
+
+		return trimmed, nil
+	case "BOOLEAN":
+		switch strings.ToLower(trimmed) {
+		case "true", "1":
+			return "true", nil
+		case "false", "0":
+			return "false", nil
+		}
+
+		return "", errSyntax
+	case "CHAR", "VARCHAR":
+		if typ.Length > 0 && len(value) > typ.Length {
+			return "", &LengthError{Length: len(value), Max: typ.Length}
+		}
+
+		return value, nil
+	case "DATE", "DATEV2":
+		if !parseTime(trimmed, dateLayouts) {
+			return "", errDate
+		}
+
+		return trimmed, nil
+	case "DATETIME", "DATETIMEV2":
+		if !parseTime(trimmed, datetimeLayouts) {
+			return "", errDatetime
+		}
+
+		return trimmed, nil
+	case "JSON", "JSONB":
+		if !json.Valid([]byte(value)) {
+			return "", errJson
+		}
+
+		return value, nil
+	}
+
+	return value, nil
+}
+
+// JsonText converts a field of a JSON object decoded with json.Decoder.UseNumber to the text Doris parses. NULL is nil.
+func JsonText(field any) *string {
+	var text string
+
+	switch field := field.(type) {
+	case nil:
+		return nil
+	case string:
+		text = field
+	case json.Number:
+		text = field.String()
+	case bool:
+		text = strconv.FormatBool(field)
+	default:
+		data, _ := json.Marshal(field)
+		text = string(data)
+	}
+
+	return &text
+}
+
+// parseTime reports whether the value matches one of the layouts.
+func parseTime(value string, layouts []string) bool {
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// numError returns the reason of a strconv error without the function and input.
+func numError(err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return numErr.Err
+	}
+
+	return err
+}
@@ -0,0 +1,86 @@
+package dorisconv_test
+
+import (
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/dorisconv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Type            dorisconv.Type
+		Value           string
+		Expected        string
+		ExpectedErr     string
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "integers should be converted to their canonical text",
+			Type:            dorisconv.Type{Name: "INT"},
+			Value:           " +042 ",
+			Expected:        "42",
+		},
+		{
+			TestDescription: "integers out of the range of the type should be rejected",
+			Type:            dorisconv.Type{Name: "TINYINT"},
+			Value:           "300",
+			ExpectedErr:     "value out of range",
+		},
+		{
+			TestDescription: "decimals with too many integer digits should be rejected",
+			Type:            dorisconv.Type{Name: "DECIMAL64", Precision: 5, Scale: 2},
+			Value:           "1000",
+			ExpectedErr:     "value out of range of DECIMAL(5, 2)",
+		},
+		{
+			TestDescription: "booleans should accept 1 and 0",
+			Type:            dorisconv.Type{Name: "boolean"},
+			Value:           "1",
+			Expected:        "true",
+		},
+		{
+			TestDescription: "strings longer than the column should be rejected with a length error",
+			Type:            dorisconv.Type{Name: "VARCHAR", Length: 8},
+			Value:           "Jonathan Doe",
+			ExpectedErr:     "length 12 exceeds 8",
+		},
+		{
+			TestDescription: "datetimes should accept the ISO 8601 layout without fraction",
+			Type:            dorisconv.Type{Name: "DATETIMEV2"},
+			Value:           "2024-01-02T15:04:05",
+			Expected:        "2024-01-02T15:04:05",
+		},
+		{
+			TestDescription: "invalid dates should be rejected",
+			Type:            dorisconv.Type{Name: "DATE"},
+			Value:           "1990-13-01",
+			ExpectedErr:     "invalid date",
+		},
+		{
+			TestDescription: "types without rules should accept any value",
+			Type:            dorisconv.Type{Name: "ARRAY"},
+			Value:           "[1, 2]",
+			Expected:        "[1, 2]",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		converted, err := dorisconv.Convert(tc.Type, tc.Value)
+		if tc.ExpectedErr != "" {
+			assert.EqualError(t, err, tc.ExpectedErr)
+			continue
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.Expected, converted)
+	}
+
+	var lengthErr *dorisconv.LengthError
+	_, err := dorisconv.Convert(dorisconv.Type{Name: "CHAR", Length: 1}, "ab")
+	assert.ErrorAs(t, err, &lengthErr)
+}
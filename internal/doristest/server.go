@@ -0,0 +1,177 @@
+// Package doristest provides a scripted Doris node for the tests needing a specific reply, such as a redirect, an unauthorized response or an error log. The tests of the behavior of a cluster use dorisfake instead.
+package doristest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Load is a stream load request received by a Server.
+type Load struct {
+	Host    string      // Host the request was sent to
+	Path    string      // Request path
+	Header  http.Header // Request header
+	Payload []byte      // Request body
+}
+
+// Response is the reply of a Server to a request.
+type Response struct {
+	Status   int    // HTTP status code (default: 200)
+	Location string // Location header of a redirect
+	Body     string // Response body
+	Drop     bool   // Whether to close the connection without a response
+}
+
+// Responder returns the response to a stream load.
+type Responder func(load Load) Response
+
+// Server is an FE or BE node answering the requests of its routes with their fixed response, and any other request as a stream load. The stream loads are recorded.
+type Server struct {
+	*httptest.Server
+
+	responder Responder
+	routes    map[string]Response
+	password  *string
+	release   <-chan struct{}
+
+	mu    sync.Mutex
+	loads []Load
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// NewServer starts a server closed at the end of the test. Stream loads get Success unless an option sets another response.
+func NewServer(t testing.TB, options ...Option) *Server {
+	t.Helper()
+
+	server := &Server{
+		responder: Success,
+		routes:    map[string]Response{},
+	}
+
+	for _, option := range options {
+		option(server)
+	}
+
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// Host returns the host and port of the server.
+func (s *Server) Host() string {
+	return strings.TrimPrefix(s.URL, "http://")
+}
+
+// Loads returns the stream loads received by the server, in the order they were received.
+func (s *Server) Loads() []Load {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Load(nil), s.loads...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// Reading the body answers "Expect: 100-continue" right away, otherwise the client waits before sending it.
+	payload, _ := io.ReadAll(r.Body)
+
+	if response, ok := s.routes[r.URL.Path]; ok {
+		write(w, response)
+		return
+	}
+
+	if s.release != nil {
+		<-s.release
+	}
+
+	load := Load{
+		Host:    r.Host,
+		Path:    r.URL.Path,
+		Header:  r.Header.Clone(),
+		Payload: payload,
+	}
+
+	s.mu.Lock()
+	s.loads = append(s.loads, load)
+	s.mu.Unlock()
+
+	if s.password != nil {
+		if _, password, ok := r.BasicAuth(); !ok || password != *s.password {
+			write(w, Response{Status: http.StatusUnauthorized})
+			return
+		}
+	}
+
+	write(w, s.responder(load))
+}
+
+// write writes the response.
+func write(w http.ResponseWriter, response Response) {
+	if response.Drop {
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			_ = conn.Close()
+		}
+		return
+	}
+
+	if response.Location != "" {
+		w.Header().Set("Location", response.Location)
+	}
+
+	if response.Status != 0 {
+		w.WriteHeader(response.Status)
+	}
+
+	_, _ = io.WriteString(w, response.Body)
+}
+
+// Success responds to the load with a successful result of its label, loading a row per line of the payload.
+func Success(load Load) Response {
+	rows := strings.Count(string(load.Payload), "\n")
+
+	return Response{
+		Body: fmt.Sprintf(`{"Status": "Success", "Label": "%s", "NumberTotalRows": %d, "NumberLoadedRows": %d}`, load.Header.Get("label"), rows, rows),
+	}
+}
+
+// WithResponder sets the response to the stream loads.
+func WithResponder(responder Responder) Option {
+	return func(server *Server) {
+		server.responder = responder
+	}
+}
+
+// WithRedirect redirects the stream loads to the location, like an FE does to a BE.
+func WithRedirect(location string) Option {
+	return WithResponder(func(load Load) Response {
+		return Response{Status: http.StatusTemporaryRedirect, Location: location}
+	})
+}
+
+// WithRoute answers the requests to the path with the response instead of handling them as stream loads, e.g. for /api/health.
+func WithRoute(path string, response Response) Option {
+	return func(server *Server) {
+		server.routes[path] = response
+	}
+}
+
+// WithPassword responds 401 Unauthorized to the stream loads without the password.
+func WithPassword(password string) Option {
+	return func(server *Server) {
+		server.password = &password
+	}
+}
+
+// WithRelease holds the stream loads until the channel is closed.
+func WithRelease(release <-chan struct{}) Option {
+	return func(server *Server) {
+		server.release = release
+	}
+}
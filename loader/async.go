@@ -0,0 +1,365 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/enum"
+	"github.com/raaaaaaaay86/doris-loader/enum/queuepolicy"
+)
+
+// AsyncResult is the result of stream loading one batch of records by AsyncLoader.
+type AsyncResult struct {
+	Table   string            // Destination table of the batch
+	Label   string            // Stream load label of the batch, empty if Doris generated it
+	Records int               // Number of records in the batch
+	Result  *StreamLoadResult // Stream load result, nil if the request failed
+	Err     error             // Request error, nil if the request was sent
+}
+
+// AsyncLoader accepts records on a bounded queue, batches them and stream loads the batches by up to Concurrency of the wrapped StreamLoader concurrently. When Doris is slower than the producers, Send blocks or returns ErrQueueFull according to QueuePolicy. The records are joined by line breaks, so the load format must be InlineJson or Csv.
+type AsyncLoader struct {
+	QueueSize      int               // Maximum number of queued records (default: 10000)
+	QueuePolicy    queuepolicy.Enum  // Behavior of Send when the queue is full (default: Block)
+	BatchSize      int               // Maximum number of records in a batch (default: 10000)
+	FlushInterval  time.Duration     // Maximum time a record waits for its batch to fill (default: 1s)
+	ResultCallback func(AsyncResult) // Called with the result of each batch instead of delivering it on Results
+
+	loader   *StreamLoader
+	limiter  chan struct{} // Limits the concurrent loads shared with other async loaders, nil if not shared
+	instance string        // Distinguishes the batch labels of this loader from the ones of previous processes
+	records  chan []byte
+	batches  chan asyncBatch
+	results  chan AsyncResult
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu        sync.RWMutex
+	closed    bool
+	closing   chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// asyncBatch is a batch of newline delimited records waiting to be loaded.
+type asyncBatch struct {
+	label   string
+	records int
+	payload []byte
+}
+
+type AsyncLoaderOption func(*AsyncLoader) error
+
+// NewAsyncLoader creates an async loader wrapping the stream loader and starts its workers. Call Close to flush the queued records and stop the workers.
+//
+// If no ResultCallback is set, the results must be received from Results, otherwise the workers stop once the results channel is full.
+func NewAsyncLoader(
+	loader *StreamLoader,
+	options ...AsyncLoaderOption,
+) (*AsyncLoader, error) {
+	if loader == nil {
+		return nil, ErrMissingRequiredValue("StreamLoader")
+	}
+
+	async := AsyncLoader{
+		QueueSize:     10000,
+		BatchSize:     10000,
+		FlushInterval: 1 * time.Second,
+		loader:        loader,
+	}
+
+	for _, option := range options {
+		if err := option(&async); err != nil {
+			return nil, err
+		}
+	}
+
+	if enum.IsZero(async.QueuePolicy) {
+		if err := WithQueuePolicy(queuepolicy.Block)(&async); err != nil {
+			return nil, err
+		}
+	}
+
+	async.start()
+
+	return &async, nil
+}
+
+// start creates the channels and starts the workers and the batching goroutine.
+func (a *AsyncLoader) start() {
+	concurrency := max(a.loader.Concurrency, 1)
+	a.instance = instanceID()
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.closing = make(chan struct{})
+	a.done = make(chan struct{})
+	a.records = make(chan []byte, a.QueueSize)
+	a.batches = make(chan asyncBatch)
+	a.results = make(chan AsyncResult, concurrency)
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			a.work()
+		}()
+	}
+
+	go a.batch()
+
+	go func() {
+		workers.Wait()
+		a.cancel()
+		close(a.results)
+		close(a.done)
+	}()
+}
+
+// Send queues a record, such as a JSON object or a CSV line, to be loaded. It'll block until there is room in the queue if QueuePolicy is Block, or return ErrQueueFull if QueuePolicy is Reject. A blocked Send returns ErrLoaderClosed once Close is called.
+func (a *AsyncLoader) Send(ctx context.Context, record []byte) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return ErrLoaderClosed
+	}
+
+	if a.QueuePolicy == queuepolicy.Reject {
+		select {
+		case a.records <- record:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case a.records <- record:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.closing:
+		return ErrLoaderClosed
+	}
+}
+
+// Results returns the channel delivering the result of each batch. It's closed after the loader is closed and every batch is loaded.
+func (a *AsyncLoader) Results() <-chan AsyncResult {
+	return a.results
+}
+
+// Close stops accepting records, loads the queued records and waits for the workers until the context is done. Once the context is done, the loads in flight are cancelled and the remaining batches fail with the cancellation error.
+func (a *AsyncLoader) Close(ctx context.Context) error {
+	a.closeOnce.Do(func() {
+		close(a.closing)
+
+		// Blocked Sends hold the read lock until they see closing, so the queue is closed without making Close wait for them.
+		go func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			a.closed = true
+			close(a.records)
+		}()
+	})
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		a.cancel()
+		return ctx.Err()
+	}
+}
+
+// batch groups the queued records into batches of BatchSize records, flushing an incomplete batch after FlushInterval.
+func (a *AsyncLoader) batch() {
+	defer close(a.batches)
+
+	ticker := time.NewTicker(a.FlushInterval)
+	defer ticker.Stop()
+
+	var buffer bytes.Buffer
+	records := 0
+	sequence := 0
+
+	flush := func() {
+		if records == 0 {
+			return
+		}
+
+		a.batches <- asyncBatch{
+			label:   a.batchLabel(sequence),
+			records: records,
+			payload: bytes.Clone(buffer.Bytes()),
+		}
+
+		buffer.Reset()
+		records = 0
+		sequence++
+	}
+
+	for {
+		select {
+		case record, ok := <-a.records:
+			if !ok {
+				flush()
+				return
+			}
+
+			buffer.Write(record)
+			if !bytes.HasSuffix(record, []byte("\n")) {
+				buffer.WriteByte('\n')
+			}
+			records++
+
+			if records >= a.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// work loads the batches and delivers their results.
+func (a *AsyncLoader) work() {
+	for batch := range a.batches {
+		if a.limiter != nil {
+			a.limiter <- struct{}{}
+		}
+
+		result, err := a.loader.load(a.ctx, bytes.NewReader(batch.payload), "", batch.label)
+
+		if a.limiter != nil {
+			<-a.limiter
+		}
+
+		asyncResult := AsyncResult{
+			Table:   a.loader.Table,
+			Label:   batch.label,
+			Records: batch.records,
+			Result:  result,
+			Err:     err,
+		}
+		if asyncResult.Label == "" && result != nil {
+			asyncResult.Label = result.Label
+		}
+
+		if a.ResultCallback != nil {
+			a.ResultCallback(asyncResult)
+			continue
+		}
+
+		a.results <- asyncResult
+	}
+}
+
+// batchLabel returns the label of the batch. Batches get the instance and a sequence suffix on the loader label if there is one, otherwise Doris generates the label. The instance keeps a restarted process from reusing the labels of the previous one, which Doris would report as already loaded without loading the new batches.
+func (a *AsyncLoader) batchLabel(sequence int) string {
+	label, ok := a.loader.Header["label"]
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("%v_%s_%d", label, a.instance, sequence)
+}
+
+// instanceID returns an identifier unique to the loader instance, made of its start time and a random number.
+func instanceID() string {
+	return fmt.Sprintf("%x%08x", time.Now().Unix(), rand.Uint32())
+}
+
+// WithQueueSize sets the maximum number of queued records. It'll return an error if there has any queue size set before.
+func WithQueueSize(size int) AsyncLoaderOption {
+	return func(loader *AsyncLoader) error {
+		if size <= 0 {
+			return ErrUnsupportValue("QueueSize")
+		}
+
+		if loader.QueueSize != 10000 && loader.QueueSize != size { // 10000 is the default value
+			return ErrAmbiguousOption("QueueSize")
+		}
+
+		loader.QueueSize = size
+
+		return nil
+	}
+}
+
+// WithQueuePolicy sets the behavior of Send when the queue is full. It'll return an error if there has any policy set before or provided an unexpected queuepolicy.Enum.
+func WithQueuePolicy(policy queuepolicy.Enum) AsyncLoaderOption {
+	return func(loader *AsyncLoader) error {
+		if !enum.IsZero(loader.QueuePolicy) && loader.QueuePolicy != policy {
+			return ErrAmbiguousOption("QueuePolicy")
+		}
+
+		switch policy {
+		case queuepolicy.Block, queuepolicy.Reject:
+			loader.QueuePolicy = policy
+		default:
+			if enum.IsZero(policy) {
+				return ErrZeroValueOption("QueuePolicy")
+			}
+
+			return ErrUnsupportValue(policy)
+		}
+
+		return nil
+	}
+}
+
+// WithBatchSize sets the maximum number of records in a batch. It'll return an error if there has any batch size set before.
+func WithBatchSize(size int) AsyncLoaderOption {
+	return func(loader *AsyncLoader) error {
+		if size <= 0 {
+			return ErrUnsupportValue("BatchSize")
+		}
+
+		if loader.BatchSize != 10000 && loader.BatchSize != size { // 10000 is the default value
+			return ErrAmbiguousOption("BatchSize")
+		}
+
+		loader.BatchSize = size
+
+		return nil
+	}
+}
+
+// WithFlushInterval sets the maximum time a record waits for its batch to fill. It'll return an error if there has any flush interval set before.
+func WithFlushInterval(interval time.Duration) AsyncLoaderOption {
+	return func(loader *AsyncLoader) error {
+		if interval <= 0 {
+			return ErrUnsupportValue("FlushInterval")
+		}
+
+		if loader.FlushInterval != 1*time.Second && loader.FlushInterval != interval { // 1 second is the default value
+			return ErrAmbiguousOption("FlushInterval")
+		}
+
+		loader.FlushInterval = interval
+
+		return nil
+	}
+}
+
+// WithResultCallback sets the function called with the result of each batch instead of delivering it on Results. It'll return an error if there has any callback set before.
+func WithResultCallback(callback func(AsyncResult)) AsyncLoaderOption {
+	return func(loader *AsyncLoader) error {
+		if callback == nil {
+			return ErrZeroValueOption("ResultCallback")
+		}
+
+		if loader.ResultCallback != nil {
+			return ErrAmbiguousOption("ResultCallback")
+		}
+
+		loader.ResultCallback = callback
+
+		return nil
+	}
+}
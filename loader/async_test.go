@@ -0,0 +1,190 @@
+package loader_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/enum/queuepolicy"
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var asyncUsers = loader.TableSchema{
+	Database: "test_db",
+	Table:    "users",
+	Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true}},
+}
+
+func TestAsyncLoader(t *testing.T) {
+	t.Log("queued records should be batched and every batch result should be delivered")
+
+	server := doristest.NewServer(t)
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithLabel("events"),
+		loader.WithConcurrency(2),
+	)
+	assert.NoError(t, err)
+
+	async, err := loader.NewAsyncLoader(
+		ld,
+		loader.WithBatchSize(10),
+		loader.WithFlushInterval(time.Hour),
+	)
+	assert.NoError(t, err)
+
+	go func() {
+		for i := 0; i < 25; i++ {
+			assert.NoError(t, async.Send(context.Background(), []byte(fmt.Sprintf(`{"name": "user_%d", "age": %d}`, i, i))))
+		}
+		assert.NoError(t, async.Close(context.Background()))
+	}()
+
+	records, loaded := 0, 0
+	labels := []string{}
+	for result := range async.Results() {
+		assert.NoError(t, result.Err)
+		assert.True(t, result.Result.IsSuccess())
+		records += result.Records
+		loaded += result.Result.NumberLoadedRows
+		labels = append(labels, result.Label)
+	}
+
+	assert.Equal(t, 25, records)
+	assert.Equal(t, 25, loaded)
+	assert.Len(t, labels, 3)
+	for _, label := range labels {
+		assert.Regexp(t, `^events_[0-9a-f]+_[0-2]$`, label)
+	}
+
+	assert.ErrorIs(t, async.Send(context.Background(), []byte(`{}`)), loader.ErrLoaderClosed)
+}
+
+func TestAsyncLoaderRejectsWhenQueueIsFull(t *testing.T) {
+	t.Log("records should be rejected with ErrQueueFull instead of queued without bound when Doris is slow")
+
+	release := make(chan struct{})
+	server := doristest.NewServer(t, doristest.WithRelease(release))
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithConcurrency(1),
+	)
+	assert.NoError(t, err)
+
+	loaded := 0
+	async, err := loader.NewAsyncLoader(
+		ld,
+		loader.WithQueueSize(1),
+		loader.WithBatchSize(1),
+		loader.WithQueuePolicy(queuepolicy.Reject),
+		loader.WithResultCallback(func(result loader.AsyncResult) {
+			loaded += result.Records
+		}),
+	)
+	assert.NoError(t, err)
+
+	sent := 0
+	assert.Eventually(t, func() bool {
+		err := async.Send(context.Background(), []byte(`{"name": "user", "age": 1}`))
+		if err == nil {
+			sent++
+			return false
+		}
+
+		return assert.ErrorIs(t, err, loader.ErrQueueFull)
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	assert.NoError(t, async.Close(context.Background()))
+	assert.Equal(t, sent, loaded)
+}
+
+func TestAsyncLoaderRestart(t *testing.T) {
+	t.Log("a restarted async loader should not reuse the batch labels of the previous one, which Doris would skip as already loaded")
+
+	server, err := dorisfake.NewServer(dorisfake.WithTable(asyncUsers))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"), loader.WithLabel("events"))
+	assert.NoError(t, err)
+
+	var results []loader.AsyncResult
+	for run := 0; run < 2; run++ {
+		async, err := loader.NewAsyncLoader(ld, loader.WithResultCallback(func(result loader.AsyncResult) {
+			results = append(results, result)
+		}))
+		assert.NoError(t, err)
+
+		assert.NoError(t, async.Send(context.Background(), []byte(fmt.Sprintf(`{"name": "run_%d"}`, run))))
+		assert.NoError(t, async.Close(context.Background()))
+	}
+
+	if assert.Len(t, results, 2) {
+		assert.NotEqual(t, results[0].Label, results[1].Label)
+		for _, result := range results {
+			assert.NoError(t, result.Err)
+			assert.True(t, result.Result.IsSuccess())
+		}
+	}
+	assert.Len(t, server.Rows("test_db", "users"), 2)
+}
+
+func TestAsyncLoaderCloseCancelsLoads(t *testing.T) {
+	t.Log("Close should return when its context is done, even behind a blocked Send, and cancel the loads in flight")
+
+	plan := dorisfake.NewFaultPlan().OnFe(dorisfake.Delay(time.Minute))
+	server, err := dorisfake.NewServer(dorisfake.WithTable(asyncUsers), dorisfake.WithFaultPlan(plan))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"), loader.WithConcurrency(1))
+	assert.NoError(t, err)
+
+	var errs []error
+	async, err := loader.NewAsyncLoader(
+		ld,
+		loader.WithQueueSize(1),
+		loader.WithBatchSize(1),
+		loader.WithResultCallback(func(result loader.AsyncResult) {
+			errs = append(errs, result.Err)
+		}),
+	)
+	assert.NoError(t, err)
+
+	// The first record is loaded for a minute, the second waits for the worker and the third fills the queue.
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, async.Send(context.Background(), []byte(`{"name": "John Doe"}`)))
+	}
+
+	blocked := make(chan error)
+	go func() {
+		blocked <- async.Send(context.Background(), []byte(`{"name": "John Doe"}`))
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.ErrorIs(t, async.Close(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, <-blocked, loader.ErrLoaderClosed)
+
+	for range async.Results() {
+	}
+	if assert.Len(t, errs, 3) {
+		for _, err := range errs {
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package loader
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/raaaaaaaay86/doris-loader/enum"
+	"github.com/raaaaaaaay86/doris-loader/enum/beselector"
+)
+
+// BeSelector selects the backend node which a redirected stream load request is sent to. The candidates are the healthy nodes of the pool, in the order they were configured. The label is the stream load label of the request and may be empty.
+type BeSelector interface {
+	Select(label string, candidates []NodeStatus) (string, error)
+}
+
+// NewBeSelector creates the built-in backend selector of the strategy. It'll return an error if provided an unexpected beselector.Enum.
+func NewBeSelector(strategy beselector.Enum) (BeSelector, error) {
+	switch strategy {
+	case beselector.RoundRobin:
+		return NewRoundRobinSelector(), nil
+	case beselector.Random:
+		return NewRandomSelector(), nil
+	case beselector.LeastInflight:
+		return NewLeastInflightSelector(), nil
+	case beselector.StickyLabel:
+		return NewStickyLabelSelector(), nil
+	default:
+		if enum.IsZero(strategy) {
+			return nil, ErrZeroValueOption("BeSelector")
+		}
+
+		return nil, ErrUnsupportValue(strategy)
+	}
+}
+
+// BeSelectorFunc adapts a function to BeSelector.
+type BeSelectorFunc func(label string, candidates []NodeStatus) (string, error)
+
+func (f BeSelectorFunc) Select(label string, candidates []NodeStatus) (string, error) {
+	return f(label, candidates)
+}
+
+// RoundRobinSelector spreads requests over the candidates in turn.
+type RoundRobinSelector struct {
+	next atomic.Uint64
+}
+
+// NewRoundRobinSelector creates a round-robin backend selector. It's the default selector.
+func NewRoundRobinSelector() *RoundRobinSelector {
+	return &RoundRobinSelector{}
+}
+
+func (s *RoundRobinSelector) Select(label string, candidates []NodeStatus) (string, error) {
+	if len(candidates) == 0 {
+		return "", ErrNoAvailableBeNode
+	}
+
+	i := s.next.Add(1) - 1
+
+	return candidates[i%uint64(len(candidates))].Addr, nil
+}
+
+// RandomSelector picks a random candidate.
+type RandomSelector struct{}
+
+// NewRandomSelector creates a random backend selector.
+func NewRandomSelector() *RandomSelector {
+	return &RandomSelector{}
+}
+
+func (s *RandomSelector) Select(label string, candidates []NodeStatus) (string, error) {
+	if len(candidates) == 0 {
+		return "", ErrNoAvailableBeNode
+	}
+
+	return candidates[rand.IntN(len(candidates))].Addr, nil
+}
+
+// LeastInflightSelector picks the candidate with the fewest inflight requests. Ties are broken by configuration order.
+type LeastInflightSelector struct{}
+
+// NewLeastInflightSelector creates a least-inflight backend selector.
+func NewLeastInflightSelector() *LeastInflightSelector {
+	return &LeastInflightSelector{}
+}
+
+func (s *LeastInflightSelector) Select(label string, candidates []NodeStatus) (string, error) {
+	if len(candidates) == 0 {
+		return "", ErrNoAvailableBeNode
+	}
+
+	selected := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.Inflight < selected.Inflight {
+			selected = candidate
+		}
+	}
+
+	return selected.Addr, nil
+}
+
+// StickyLabelSelector sends requests with the same label to the same candidate by rendezvous hashing, so that retries of a load land on the same backend while it stays healthy. Requests without label fall back to round-robin.
+type StickyLabelSelector struct {
+	fallback RoundRobinSelector
+}
+
+// NewStickyLabelSelector creates a sticky-by-label backend selector.
+func NewStickyLabelSelector() *StickyLabelSelector {
+	return &StickyLabelSelector{}
+}
+
+func (s *StickyLabelSelector) Select(label string, candidates []NodeStatus) (string, error) {
+	if label == "" {
+		return s.fallback.Select(label, candidates)
+	}
+
+	if len(candidates) == 0 {
+		return "", ErrNoAvailableBeNode
+	}
+
+	var selected string
+	var highest uint64
+	for _, candidate := range candidates {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(label))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(candidate.Addr))
+
+		if score := h.Sum64(); selected == "" || score > highest {
+			selected = candidate.Addr
+			highest = score
+		}
+	}
+
+	return selected, nil
+}
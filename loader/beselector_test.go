@@ -0,0 +1,168 @@
+package loader_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBeSelectors(t *testing.T) {
+	candidates := []loader.NodeStatus{
+		{Addr: "be1:8040", Healthy: true, Inflight: 2},
+		{Addr: "be2:8040", Healthy: true, Inflight: 0},
+		{Addr: "be3:8040", Healthy: true, Inflight: 1},
+	}
+
+	t.Log("round-robin selector should visit every candidate in turn")
+	roundRobin := loader.NewRoundRobinSelector()
+	for _, expected := range []string{"be1:8040", "be2:8040", "be3:8040", "be1:8040"} {
+		selected, err := roundRobin.Select("", candidates)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, selected)
+	}
+
+	t.Log("least-inflight selector should pick the least busy candidate")
+	selected, err := loader.NewLeastInflightSelector().Select("", candidates)
+	assert.NoError(t, err)
+	assert.Equal(t, "be2:8040", selected)
+
+	t.Log("sticky selector should keep a label on the same candidate")
+	sticky := loader.NewStickyLabelSelector()
+	first, err := sticky.Select("label_a", candidates)
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		selected, err := sticky.Select("label_a", candidates)
+		assert.NoError(t, err)
+		assert.Equal(t, first, selected)
+	}
+
+	t.Log("random selector should pick one of the candidates")
+	selected, err = loader.NewRandomSelector().Select("", candidates)
+	assert.NoError(t, err)
+	assert.Contains(t, []string{"be1:8040", "be2:8040", "be3:8040"}, selected)
+
+	t.Log("every selector should fail without candidates")
+	for _, selector := range []loader.BeSelector{
+		loader.NewRoundRobinSelector(),
+		loader.NewRandomSelector(),
+		loader.NewLeastInflightSelector(),
+		loader.NewStickyLabelSelector(),
+	} {
+		_, err := selector.Select("label_a", nil)
+		assert.ErrorIs(t, err, loader.ErrNoAvailableBeNode)
+	}
+}
+
+func TestLoadFileSpreadsRedirectsOverBeNodes(t *testing.T) {
+	t.Log("redirected loads should be spread over the BE nodes by round-robin")
+
+	fe := doristest.NewServer(t, doristest.WithRedirect("http://backend.invalid/"))
+	be1 := doristest.NewServer(t)
+	be2 := doristest.NewServer(t)
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe.Host()},
+		"test_db",
+		"users",
+		loader.WithBeNodes([]string{be1.Host(), be2.Host()}),
+	)
+	assert.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+		assert.NoError(t, err)
+		assert.True(t, result.IsSuccess())
+	}
+
+	assert.Len(t, be1.Loads(), 2)
+	assert.Len(t, be2.Loads(), 2)
+}
+
+func TestLoadFileSkipsUnavailableBeNode(t *testing.T) {
+	t.Log("a failed BE node should be skipped until its cooldown expires")
+
+	fe := doristest.NewServer(t, doristest.WithRedirect("http://backend.invalid/"))
+	be := doristest.NewServer(t)
+	dead := doristest.NewServer(t)
+	deadHost := dead.Host()
+	dead.Close()
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe.Host()},
+		"test_db",
+		"users",
+		loader.WithBeNodes([]string{deadHost, be.Host()}),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithNodeCooldown(time.Minute),
+	)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+		assert.NoError(t, err)
+		assert.True(t, result.IsSuccess())
+	}
+
+	assert.Len(t, be.Loads(), 3)
+
+	status := ld.BeStatus()
+	assert.False(t, status[0].Healthy)
+	assert.NotEmpty(t, status[0].LastError)
+	assert.True(t, status[1].Healthy)
+}
+
+func TestLoadFileFailsWithoutAvailableBeNode(t *testing.T) {
+	t.Log("loads should fail clearly when every BE node is unavailable")
+
+	fe := doristest.NewServer(t, doristest.WithRedirect("http://backend.invalid/"))
+	dead := doristest.NewServer(t)
+	deadHost := dead.Host()
+	dead.Close()
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe.Host()},
+		"test_db",
+		"users",
+		loader.WithBeNodes([]string{deadHost}),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithNodeCooldown(time.Minute),
+	)
+	assert.NoError(t, err)
+
+	_, err = ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.ErrorIs(t, err, loader.ErrNoAvailableBeNode)
+}
+
+func TestLoadFileKeepsBeNodeAfterDroppedConnection(t *testing.T) {
+	t.Log("a connection dropped by the only BE node should be retried without benching the node")
+
+	drops := 1
+	be := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		if drops > 0 {
+			drops--
+			return doristest.Response{Drop: true}
+		}
+
+		return doristest.Success(load)
+	}))
+	fe := doristest.NewServer(t, doristest.WithRedirect("http://backend.invalid/"))
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe.Host()},
+		"test_db",
+		"users",
+		loader.WithBeNodes([]string{be.Host()}),
+		loader.WithRetryInterval(time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Len(t, be.Loads(), 2)
+	assert.True(t, ld.BeStatus()[0].Healthy)
+}
@@ -0,0 +1,121 @@
+package loader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CheckpointEntry is the outcome of the last load of a file recorded in the checkpoint file.
+type CheckpointEntry struct {
+	Label     string    `json:"label"`
+	Status    string    `json:"status"`
+	Message   string    `json:"message,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// checkpoint records the outcome of each loaded file in a local JSON file, so that a rerun skips the files already loaded.
+type checkpoint struct {
+	path  string
+	mu    sync.Mutex
+	Files map[string]CheckpointEntry `json:"files"`
+}
+
+// openCheckpoint reads the checkpoint file. A missing file is an empty checkpoint.
+func openCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{
+		path:  path,
+		Files: map[string]CheckpointEntry{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	if c.Files == nil {
+		c.Files = map[string]CheckpointEntry{}
+	}
+
+	return c, nil
+}
+
+// isLoaded reports whether the file was loaded with the label before.
+func (c *checkpoint) isLoaded(filename string, label string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.Files[checkpointKey(filename)]
+
+	return ok && entry.Label == label && entry.Status == "Success"
+}
+
+// record saves the outcome of loading the file and writes the checkpoint file atomically.
+func (c *checkpoint) record(filename string, label string, result *StreamLoadResult, err error) error {
+	entry := CheckpointEntry{
+		Label:     label,
+		Status:    "Fail",
+		UpdatedAt: time.Now(),
+	}
+
+	switch {
+	case err != nil:
+		entry.Message = err.Error()
+	case result.IsLoaded():
+		entry.Status = "Success"
+	default:
+		entry.Message = result.Message
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Files[checkpointKey(filename)] = entry
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path)
+}
+
+// checkpointKey returns the absolute path of the file, so that the checkpoint doesn't depend on the working directory.
+func checkpointKey(filename string) string {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return filename
+	}
+
+	return path
+}
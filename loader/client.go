@@ -0,0 +1,166 @@
+package loader
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+)
+
+// Client holds the cluster-level configuration shared by the loaders of many tables: the FE and BE nodes with their health state, the credentials, the HTTP transport and the defaults of the other options. Table creates lightweight loaders sharing them.
+type Client struct {
+	loader StreamLoader
+}
+
+// clientFields are the fields of the node pools and the transport, which can only be set on the client.
+var clientFields = []string{"FeNodes", "BeNodes", "NodeCooldown", "HealthCheckInterval", "BeDiscoveryInterval", "Transport"}
+
+// credentialFields are overridden together, since a CredentialsProvider takes precedence over Username and Password.
+var credentialFields = []string{"Username", "Password", "Credentials"}
+
+// formatHeaders are the headers set by WithLoadFormat, which are dropped from the header of the client when a table sets another format.
+var formatHeaders = []string{"format", "read_json_by_line"}
+
+// NewClient creates a client of the cluster. The options are the ones of NewStreamLoader and become the defaults of the loaders created by Table. Call Close to stop the background health check and BE discovery.
+//
+//	client, err := loader.NewClient(
+//		[]string{"127.0.0.1:8030"},
+//		loader.WithUsername("root"),
+//		loader.WithMaxRetry(5),
+//	)
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//
+//	users, err := client.Table("db_name", "users")
+//	orders, err := client.Table("db_name", "orders", loader.WithMaxRetry(1))
+func NewClient(
+	feNodes []string,
+	options ...StreamLoaderOption,
+) (*Client, error) {
+	if len(feNodes) == 0 {
+		return nil, ErrMissingRequiredValue("FeNodes")
+	}
+
+	loader := newStreamLoader(feNodes, "", "")
+
+	for _, option := range options {
+		if err := option(&loader); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := loader.applyDefaults(); err != nil {
+		return nil, err
+	}
+
+	loader.fePool = NewNodePool(loader.FeNodes, loader.NodeCooldown)
+	loader.bePool = NewNodePool(loader.BeNodes, loader.NodeCooldown)
+	loader.startBackground()
+
+	return &Client{loader: loader}, nil
+}
+
+// Table returns a loader of the table sharing the node pools, credentials and transport of the client. The options override the defaults of the client for this table only, and their conflicts are checked among themselves, so a table may set another value than the client but not two values. The header is merged with the header of the client and the middlewares run inside the ones of the client.
+//
+// An option overrides the client even if it sets the default value, and setting the LoadFormat replaces the format headers of the client. The options of the nodes and the transport only apply to the client, so they return ErrClientOption here. Closing the loader doesn't stop the client.
+func (c *Client) Table(
+	database string,
+	table string,
+	options ...StreamLoaderOption,
+) (*StreamLoader, error) {
+	layer := newStreamLoader(nil, database, table)
+
+	for _, option := range options {
+		if err := option(&layer); err != nil {
+			return nil, err
+		}
+	}
+
+	loader := c.loader
+	loader.Database = database
+	loader.Table = table
+	loader.schema = &schemaCache{}
+	loader.stopBackground = nil
+
+	if err := loader.inherit(layer); err != nil {
+		return nil, err
+	}
+
+	if err := loader.checkRequiredFields(); err != nil {
+		return nil, err
+	}
+
+	if loader.ValidateSchema {
+		if err := loader.validateSchema(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSchemaValidation, err)
+		}
+	}
+
+	return &loader, nil
+}
+
+// FeStatus returns the cached health state of the FE nodes shared by the loaders of the client.
+func (c *Client) FeStatus() []NodeStatus {
+	return c.loader.FeStatus()
+}
+
+// BeStatus returns the cached health state of the BE nodes shared by the loaders of the client.
+func (c *Client) BeStatus() []NodeStatus {
+	return c.loader.BeStatus()
+}
+
+// Close stops the background health check and BE discovery of the client.
+func (c *Client) Close() error {
+	return c.loader.Close()
+}
+
+// inherit overrides the fields of the loader with the fields set by the options applied to the layer.
+func (s *StreamLoader) inherit(layer StreamLoader) error {
+	target := reflect.ValueOf(s).Elem()
+	overrides := reflect.ValueOf(layer)
+
+	overridesCredentials := false
+	for _, name := range slices.Sorted(maps.Keys(layer.appliedFields)) {
+		switch {
+		case slices.Contains(clientFields, name):
+			return ErrClientOption(name)
+		case slices.Contains(credentialFields, name):
+			overridesCredentials = true
+		default:
+			target.FieldByName(name).Set(overrides.FieldByName(name))
+		}
+	}
+
+	if overridesCredentials {
+		for _, name := range credentialFields {
+			target.FieldByName(name).Set(overrides.FieldByName(name))
+		}
+	}
+
+	header := maps.Clone(s.Header)
+	if header == nil {
+		header = map[string]any{}
+	}
+	if layer.appliedFields["LoadFormat"] {
+		for _, key := range formatHeaders {
+			delete(header, key)
+		}
+	}
+	maps.Copy(header, layer.Header)
+	s.Header = header
+
+	s.Middlewares = append(slices.Clip(s.Middlewares), layer.Middlewares...)
+
+	return nil
+}
+
+// markApplied records that an option set the field.
+func (s *StreamLoader) markApplied(field string) {
+	if s.appliedFields == nil {
+		s.appliedFields = map[string]bool{}
+	}
+
+	s.appliedFields[field] = true
+}
@@ -0,0 +1,175 @@
+package loader_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	t.Log("table loaders should inherit the client defaults and override them per table")
+
+	options := []dorisfake.Option{dorisfake.WithCredentials("root", "secret")}
+	for _, table := range []string{"users", "orders"} {
+		options = append(options, dorisfake.WithTable(loader.TableSchema{
+			Database: "test_db",
+			Table:    table,
+			Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Nullable: true}},
+		}))
+	}
+
+	server, err := dorisfake.NewServer(options...)
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	transport := &countingTransport{paths: map[string]int{}}
+	client, err := loader.NewClient(
+		append([]string{"127.0.0.1:1"}, server.FeNodes()...),
+		loader.WithUsername("root"),
+		loader.WithPassword("secret"),
+		loader.WithMaxRetry(5),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithColumnSeparator(","),
+		loader.WithTransport(transport),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	users, err := client.Table("test_db", "users")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, users.MaxRetry)
+
+	// The provider of orders starts with an expired password, so that it's refreshed if orders uses it instead of the credentials of the client.
+	provider := &rotatingCredentialsProvider{password: "expired", next: "secret"}
+	orders, err := client.Table(
+		"test_db",
+		"orders",
+		loader.WithMaxRetry(2),
+		loader.WithLabel("orders_label"),
+		loader.WithCredentialsProvider(provider),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, orders.MaxRetry)
+	assert.Equal(t, 5, users.MaxRetry)
+
+	for _, ld := range []*loader.StreamLoader{users, orders} {
+		result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+		assert.NoError(t, err)
+		assert.True(t, result.IsSuccess())
+	}
+
+	assert.Equal(t, 1, provider.refreshed)
+
+	loads := map[string]dorisfake.Load{}
+	for _, load := range server.Loads() {
+		loads[load.Table] = load
+	}
+
+	assert.Equal(t, ",", loads["users"].Header.Get("column_separator"))
+	assert.Empty(t, loads["users"].Header.Get("label"))
+	assert.Equal(t, ",", loads["orders"].Header.Get("column_separator"))
+	assert.Equal(t, "orders_label", loads["orders"].Header.Get("label"))
+
+	t.Log("the table loaders should share the node health state and the transport of the client")
+
+	// users marked the unreachable FE down, so orders skipped it. Both were redirected to the BE, and orders was unauthorized once.
+	assert.Equal(t, 3, transport.paths["/api/test_db/users/_stream_load"])
+	assert.Equal(t, 3, transport.paths["/api/test_db/orders/_stream_load"])
+	for _, status := range client.FeStatus() {
+		assert.Equal(t, status.Addr != "127.0.0.1:1", status.Healthy, status.Addr)
+	}
+	assert.Equal(t, client.FeStatus(), orders.FeStatus())
+}
+
+func TestClientTableOverrides(t *testing.T) {
+	t.Log("a table should override the client with the default value, and a table format should replace the format headers of the client")
+
+	client, err := loader.NewClient(
+		[]string{"127.0.0.1:8030"},
+		loader.WithMaxRetry(5),
+		loader.WithConcurrency(8),
+		loader.WithLoadFormat(loadformat.InlineJson),
+	)
+	assert.NoError(t, err)
+
+	users, err := client.Table("test_db", "users")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, users.MaxRetry)
+	assert.Equal(t, 8, users.Concurrency)
+	assert.Equal(t, loadformat.InlineJson, users.LoadFormat)
+	assert.Equal(t, "json", users.Header["format"])
+	assert.Equal(t, true, users.Header["read_json_by_line"])
+
+	orders, err := client.Table(
+		"test_db",
+		"orders",
+		loader.WithMaxRetry(3),
+		loader.WithConcurrency(4),
+		loader.WithLoadFormat(loadformat.Csv),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, orders.MaxRetry)
+	assert.Equal(t, 4, orders.Concurrency)
+	assert.Equal(t, loadformat.Csv, orders.LoadFormat)
+	assert.Equal(t, "csv", orders.Header["format"])
+	assert.NotContains(t, orders.Header, "read_json_by_line")
+	assert.Equal(t, "100-continue", orders.Header["expect"])
+
+	assert.Equal(t, 5, users.MaxRetry)
+	assert.Equal(t, true, users.Header["read_json_by_line"])
+}
+
+func TestClientOptions(t *testing.T) {
+	t.Log("conflicting options should be rejected within a layer, and node options should be rejected on tables")
+
+	_, err := loader.NewClient(nil)
+	assert.ErrorContains(t, err, loader.ErrMissingRequiredValue("FeNodes").Error())
+
+	_, err = loader.NewClient([]string{"127.0.0.1:8030"}, loader.WithMaxRetry(5), loader.WithMaxRetry(2))
+	assert.ErrorContains(t, err, loader.ErrAmbiguousOption("MaxRetry").Error())
+
+	client, err := loader.NewClient([]string{"127.0.0.1:8030"}, loader.WithMaxRetry(5), loader.WithLabel("client_label"))
+	assert.NoError(t, err)
+
+	_, err = client.Table("test_db", "users", loader.WithMaxRetry(2), loader.WithMaxRetry(1))
+	assert.ErrorContains(t, err, loader.ErrAmbiguousOption("MaxRetry").Error())
+
+	ld, err := client.Table("test_db", "users", loader.WithLabel("table_label"))
+	assert.NoError(t, err)
+	assert.Equal(t, "table_label", ld.Header["label"])
+
+	_, err = client.Table("test_db", "users", loader.WithBeNodes([]string{"127.0.0.1:8040"}))
+	assert.ErrorContains(t, err, loader.ErrClientOption("BeNodes").Error())
+
+	_, err = client.Table("test_db", "")
+	assert.ErrorContains(t, err, loader.ErrMissingRequiredValue("Table").Error())
+}
+
+func TestClientTableSchemaValidation(t *testing.T) {
+	t.Log("a table loader failing the schema validation should be rejected with ErrSchemaValidation")
+
+	server, err := dorisfake.NewServer(dorisfake.WithTable(loader.TableSchema{
+		Database: "test_db",
+		Table:    "users",
+		Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true}},
+	}))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	client, err := loader.NewClient(server.FeNodes(), loader.WithUsername("root"), loader.WithSchemaValidation())
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	_, err = client.Table("test_db", "users", loader.WithColumns([]string{"name"}))
+	assert.NoError(t, err)
+
+	_, err = client.Table("test_db", "users", loader.WithColumns([]string{"nmae"}))
+	assert.ErrorIs(t, err, loader.ErrSchemaValidation)
+	assert.ErrorContains(t, err, "column nmae does not exist")
+}
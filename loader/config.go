@@ -0,0 +1,348 @@
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/enum/beselector"
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/enum/protocol"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a declarative definition of a cluster and the load jobs run against it. It's decoded from YAML or JSON.
+//
+//	cluster:
+//	  fe_nodes: [127.0.0.1:8030]
+//	  credentials:
+//	    username: root
+//	    password_env: DORIS_PASSWORD
+//	jobs:
+//	  users:
+//	    database: db_name
+//	    table: users
+//	    format: csv
+//	    column_separator: ","
+//	    max_filter_ratio: 0
+type Config struct {
+	Cluster ClusterConfig        `yaml:"cluster" json:"cluster"` // Cluster shared by the jobs
+	Jobs    map[string]JobConfig `yaml:"jobs" json:"jobs"`       // Load jobs by name
+}
+
+// ClusterConfig describes the endpoints and credentials of a cluster.
+type ClusterConfig struct {
+	Protocol            protocol.Enum     `yaml:"protocol" json:"protocol"`                           // http or https (default: http)
+	FeNodes             []string          `yaml:"fe_nodes" json:"fe_nodes"`                           // Frontend endpoints
+	BeNodes             []string          `yaml:"be_nodes" json:"be_nodes"`                           // Backend endpoints
+	BeSelector          beselector.Enum   `yaml:"be_selector" json:"be_selector"`                     // Strategy selecting the BE node (default: round_robin)
+	BeDiscoveryInterval Duration          `yaml:"be_discovery_interval" json:"be_discovery_interval"` // Interval of discovering BE nodes, instead of be_nodes
+	HealthCheckInterval Duration          `yaml:"health_check_interval" json:"health_check_interval"` // Interval of the background FE health check
+	NodeCooldown        Duration          `yaml:"node_cooldown" json:"node_cooldown"`                 // Duration an unavailable node is skipped for (default: 30s)
+	Credentials         CredentialsConfig `yaml:"credentials" json:"credentials"`                     // Credentials of the cluster
+}
+
+// CredentialsConfig refers to the credentials of a cluster. Literal values, environment variables and files cannot be mixed, except a literal username with password_env.
+type CredentialsConfig struct {
+	Username     string `yaml:"username" json:"username"`           // Literal username
+	Password     string `yaml:"password" json:"password"`           // Literal password
+	UsernameEnv  string `yaml:"username_env" json:"username_env"`   // Environment variable holding the username, read on each request
+	PasswordEnv  string `yaml:"password_env" json:"password_env"`   // Environment variable holding the password
+	UsernameFile string `yaml:"username_file" json:"username_file"` // File holding the username, reloaded when modified
+	PasswordFile string `yaml:"password_file" json:"password_file"` // File holding the password, reloaded when modified
+}
+
+// JobConfig describes the loads of one table. Unset fields keep the defaults of NewStreamLoader.
+type JobConfig struct {
+	Database        string          `yaml:"database" json:"database"`                 // Database name
+	Table           string          `yaml:"table" json:"table"`                       // Table name
+	Format          loadformat.Enum `yaml:"format" json:"format"`                     // Load format (default: inline_json)
+	ColumnSeparator string          `yaml:"column_separator" json:"column_separator"` // Column separator of CSV
+	Columns         []string        `yaml:"columns" json:"columns"`                   // Columns of the payload
+	Label           string          `yaml:"label" json:"label"`                       // Stream load label
+	MaxFilterRatio  *float64        `yaml:"max_filter_ratio" json:"max_filter_ratio"` // Maximum ratio of filtered rows
+	MaxRetry        *int            `yaml:"max_retry" json:"max_retry"`               // Maximum retry count (default: 3)
+	RetryInterval   Duration        `yaml:"retry_interval" json:"retry_interval"`     // Retry interval (default: 1s)
+	Header          map[string]any  `yaml:"header" json:"header"`                     // Extra stream load header
+	ChunkSize       int64           `yaml:"chunk_size" json:"chunk_size"`             // Chunk size in bytes of LoadFileParallel (default: 100MB)
+	Concurrency     int             `yaml:"concurrency" json:"concurrency"`           // Maximum number of concurrent loads (default: 4)
+	CheckpointFile  string          `yaml:"checkpoint_file" json:"checkpoint_file"`   // Checkpoint file of LoadDir and LoadGlob
+	DeadLetterFile  string          `yaml:"dead_letter_file" json:"dead_letter_file"` // File receiving the rows filtered by Doris
+	ValidateSchema  bool            `yaml:"validate_schema" json:"validate_schema"`   // Whether to validate the job against the table schema
+}
+
+// Duration is a time.Duration written as a string like "1s" or "5m" in the config.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+
+	return nil
+}
+
+// UnmarshalYAML reports the line of an invalid duration, since the error of UnmarshalText alone doesn't tell which field it is.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if err := d.UnmarshalText([]byte(node.Value)); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+
+	return nil
+}
+
+// LoadConfig reads the config from a YAML or JSON file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(data)
+}
+
+// ParseConfig decodes the config from YAML or JSON, which is a subset of YAML. Unknown fields are rejected so that typos don't go unnoticed.
+func ParseConfig(data []byte) (*Config, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var config Config
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// JobNames returns the names of the jobs in alphabetical order.
+func (c Config) JobNames() []string {
+	names := make([]string, 0, len(c.Jobs))
+	for name := range c.Jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// FromConfig creates the stream loader of the job. The options are applied after the ones of the config, so they can add settings the config leaves unset. Errors of the config are prefixed with the path of the field, like jobs.users.max_filter_ratio.
+func FromConfig(
+	config *Config,
+	job string,
+	options ...StreamLoaderOption,
+) (*StreamLoader, error) {
+	jobConfig, ok := config.Jobs[job]
+	if !ok {
+		return nil, ErrInvalidConfig("jobs."+job, errors.New("job is not defined"))
+	}
+
+	path := "jobs." + job
+
+	if len(config.Cluster.FeNodes) == 0 {
+		return nil, ErrInvalidConfig("cluster.fe_nodes", ErrMissingRequiredValue("FeNodes"))
+	}
+
+	if jobConfig.Database == "" {
+		return nil, ErrInvalidConfig(path+".database", ErrMissingRequiredValue("Database"))
+	}
+
+	if jobConfig.Table == "" {
+		return nil, ErrInvalidConfig(path+".table", ErrMissingRequiredValue("Table"))
+	}
+
+	configOptions, err := config.Cluster.options()
+	if err != nil {
+		return nil, err
+	}
+	configOptions = append(configOptions, jobConfig.options(path)...)
+
+	return NewStreamLoader(
+		config.Cluster.FeNodes,
+		jobConfig.Database,
+		jobConfig.Table,
+		append(configOptions, options...)...,
+	)
+}
+
+// options converts the cluster config to stream loader options.
+func (c ClusterConfig) options() ([]StreamLoaderOption, error) {
+	var options []StreamLoaderOption
+
+	if c.Protocol != "" {
+		options = append(options, configOption("cluster.protocol", WithProtocol(c.Protocol)))
+	}
+
+	if len(c.BeNodes) > 0 {
+		options = append(options, configOption("cluster.be_nodes", WithBeNodes(c.BeNodes)))
+	}
+
+	if c.BeSelector != "" {
+		selector, err := NewBeSelector(c.BeSelector)
+		if err != nil {
+			return nil, ErrInvalidConfig("cluster.be_selector", err)
+		}
+		options = append(options, configOption("cluster.be_selector", WithBeSelector(selector)))
+	}
+
+	if c.BeDiscoveryInterval != 0 {
+		options = append(options, configOption("cluster.be_discovery_interval", WithBeDiscovery(time.Duration(c.BeDiscoveryInterval))))
+	}
+
+	if c.HealthCheckInterval != 0 {
+		options = append(options, configOption("cluster.health_check_interval", WithHealthCheck(time.Duration(c.HealthCheckInterval))))
+	}
+
+	if c.NodeCooldown != 0 {
+		options = append(options, configOption("cluster.node_cooldown", WithNodeCooldown(time.Duration(c.NodeCooldown))))
+	}
+
+	credentials, err := c.Credentials.options()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(options, credentials...), nil
+}
+
+// options converts the credentials config to stream loader options.
+func (c CredentialsConfig) options() ([]StreamLoaderOption, error) {
+	path := "cluster.credentials"
+
+	if c.UsernameFile != "" || c.PasswordFile != "" {
+		if c.Username != "" || c.Password != "" || c.UsernameEnv != "" || c.PasswordEnv != "" {
+			return nil, ErrInvalidConfig(path, ErrAmbiguousOption("Credentials"))
+		}
+
+		if c.UsernameFile == "" {
+			return nil, ErrInvalidConfig(path+".username_file", ErrMissingRequiredValue("UsernameFile"))
+		}
+
+		if c.PasswordFile == "" {
+			return nil, ErrInvalidConfig(path+".password_file", ErrMissingRequiredValue("PasswordFile"))
+		}
+
+		provider, err := NewFileCredentialsProvider(c.UsernameFile, c.PasswordFile)
+		if err != nil {
+			return nil, ErrInvalidConfig(path, err)
+		}
+
+		return []StreamLoaderOption{configOption(path, WithCredentialsProvider(provider))}, nil
+	}
+
+	if c.UsernameEnv != "" {
+		if c.Username != "" || c.Password != "" {
+			return nil, ErrInvalidConfig(path, ErrAmbiguousOption("Credentials"))
+		}
+
+		provider := NewEnvCredentialsProvider(c.UsernameEnv, c.PasswordEnv)
+
+		return []StreamLoaderOption{configOption(path, WithCredentialsProvider(provider))}, nil
+	}
+
+	password := c.Password
+	if c.PasswordEnv != "" {
+		if c.Password != "" {
+			return nil, ErrInvalidConfig(path, ErrAmbiguousOption("Password"))
+		}
+
+		value, ok := os.LookupEnv(c.PasswordEnv)
+		if !ok {
+			return nil, ErrInvalidConfig(path+".password_env", fmt.Errorf("environment variable is not set: %s", c.PasswordEnv))
+		}
+		password = value
+	}
+
+	var options []StreamLoaderOption
+
+	if c.Username != "" {
+		options = append(options, configOption(path+".username", WithUsername(c.Username)))
+	}
+
+	if password != "" {
+		options = append(options, configOption(path+".password", WithPassword(password)))
+	}
+
+	return options, nil
+}
+
+// options converts the job config to stream loader options.
+func (c JobConfig) options(path string) []StreamLoaderOption {
+	var options []StreamLoaderOption
+
+	// The header goes first, so that the dedicated fields are checked against it.
+	if len(c.Header) > 0 {
+		options = append(options, configOption(path+".header", WithHeader(c.Header)))
+	}
+
+	if c.Format != "" {
+		options = append(options, configOption(path+".format", WithLoadFormat(c.Format)))
+	}
+
+	if c.ColumnSeparator != "" {
+		options = append(options, configOption(path+".column_separator", WithColumnSeparator(c.ColumnSeparator)))
+	}
+
+	if len(c.Columns) > 0 {
+		options = append(options, configOption(path+".columns", WithColumns(c.Columns)))
+	}
+
+	if c.Label != "" {
+		options = append(options, configOption(path+".label", WithLabel(c.Label)))
+	}
+
+	if c.MaxFilterRatio != nil {
+		options = append(options, configOption(path+".max_filter_ratio", WithMaxFilterRatio(*c.MaxFilterRatio)))
+	}
+
+	if c.MaxRetry != nil {
+		options = append(options, configOption(path+".max_retry", WithMaxRetry(*c.MaxRetry)))
+	}
+
+	if c.RetryInterval != 0 {
+		options = append(options, configOption(path+".retry_interval", WithRetryInterval(time.Duration(c.RetryInterval))))
+	}
+
+	if c.ChunkSize != 0 {
+		options = append(options, configOption(path+".chunk_size", WithChunkSize(c.ChunkSize)))
+	}
+
+	if c.Concurrency != 0 {
+		options = append(options, configOption(path+".concurrency", WithConcurrency(c.Concurrency)))
+	}
+
+	if c.CheckpointFile != "" {
+		options = append(options, configOption(path+".checkpoint_file", WithCheckpointFile(c.CheckpointFile)))
+	}
+
+	if c.DeadLetterFile != "" {
+		options = append(options, configOption(path+".dead_letter_file", WithDeadLetterSink(NewFileDeadLetterSink(c.DeadLetterFile))))
+	}
+
+	if c.ValidateSchema {
+		options = append(options, configOption(path+".validate_schema", WithSchemaValidation()))
+	}
+
+	return options
+}
+
+// configOption prefixes the error of the option with the path of the config field it came from.
+func configOption(path string, option StreamLoaderOption) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if err := option(loader); err != nil {
+			return ErrInvalidConfig(path, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,126 @@
+package loader_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromConfig(t *testing.T) {
+	t.Log("a YAML config should produce the loader of the job")
+
+	t.Setenv("TEST_DORIS_PASSWORD", "changeme")
+
+	path := filepath.Join(t.TempDir(), "jobs.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+cluster:
+  fe_nodes: [127.0.0.1:8030, 127.0.0.2:8030]
+  be_nodes: [127.0.0.1:8040]
+  be_selector: sticky_label
+  node_cooldown: 1m
+  credentials:
+    username: root
+    password_env: TEST_DORIS_PASSWORD
+jobs:
+  users:
+    database: test_db
+    table: users
+    format: csv
+    column_separator: "|"
+    columns: [name, age]
+    max_filter_ratio: 0
+    retry_interval: 5s
+    header:
+      timeout: 600
+`), 0o600))
+
+	config, err := loader.LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"users"}, config.JobNames())
+
+	ld, err := loader.FromConfig(config, "users", loader.WithMaxRetry(5))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"127.0.0.1:8030", "127.0.0.2:8030"}, ld.FeNodes)
+	assert.Equal(t, []string{"127.0.0.1:8040"}, ld.BeNodes)
+	assert.IsType(t, &loader.StickyLabelSelector{}, ld.BeSelector)
+	assert.Equal(t, time.Minute, ld.NodeCooldown)
+	assert.Equal(t, "root", ld.Username)
+	assert.Equal(t, "changeme", ld.Password)
+	assert.Equal(t, "test_db", ld.Database)
+	assert.Equal(t, "users", ld.Table)
+	assert.Equal(t, loadformat.Csv, ld.LoadFormat)
+	assert.Equal(t, "|", ld.Header["column_separator"])
+	assert.Equal(t, "name,age", ld.Header["columns"])
+	assert.Equal(t, float64(0), ld.Header["max_filter_ratio"])
+	assert.Equal(t, 600, ld.Header["timeout"])
+	assert.Equal(t, 5*time.Second, ld.RetryInterval)
+	assert.Equal(t, 5, ld.MaxRetry)
+}
+
+func TestFromConfigErrors(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Config          string
+		Job             string
+		ExpectedError   string
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "an unknown field should be rejected",
+			Config:          `{"cluster": {"fe_node": ["127.0.0.1:8030"]}}`,
+			ExpectedError:   "yaml: unmarshal errors:\n  line 1: field fe_node not found in type loader.ClusterConfig",
+		},
+		{
+			TestDescription: "an undefined job should be reported",
+			Config:          `{"cluster": {"fe_nodes": ["127.0.0.1:8030"]}}`,
+			Job:             "users",
+			ExpectedError:   "invalid config: jobs.users: job is not defined",
+		},
+		{
+			TestDescription: "a missing table should be reported with the field path",
+			Config:          `{"cluster": {"fe_nodes": ["127.0.0.1:8030"]}, "jobs": {"users": {"database": "test_db"}}}`,
+			Job:             "users",
+			ExpectedError:   "invalid config: jobs.users.table: missing required value: Table",
+		},
+		{
+			TestDescription: "an invalid option value should be reported with the field path",
+			Config:          `{"cluster": {"fe_nodes": ["127.0.0.1:8030"]}, "jobs": {"users": {"database": "test_db", "table": "users", "max_filter_ratio": 2}}}`,
+			Job:             "users",
+			ExpectedError:   "invalid config: jobs.users.max_filter_ratio: unsupported value: MaxFilterRatio",
+		},
+		{
+			TestDescription: "an unexpected enum value should be reported with the field path",
+			Config:          `{"cluster": {"fe_nodes": ["127.0.0.1:8030"], "be_selector": "fastest"}, "jobs": {"users": {"database": "test_db", "table": "users"}}}`,
+			Job:             "users",
+			ExpectedError:   "invalid config: cluster.be_selector: unsupported value: fastest",
+		},
+		{
+			TestDescription: "an invalid duration should be rejected",
+			Config:          `{"jobs": {"users": {"retry_interval": "soon"}}}`,
+			ExpectedError:   `line 1: time: invalid duration "soon"`,
+		},
+		{
+			TestDescription: "mixed credentials references should be rejected",
+			Config:          `{"cluster": {"fe_nodes": ["127.0.0.1:8030"], "credentials": {"password": "a", "password_file": "b"}}, "jobs": {"users": {"database": "test_db", "table": "users"}}}`,
+			Job:             "users",
+			ExpectedError:   "invalid config: cluster.credentials: ambiguous option: Credentials",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		config, err := loader.ParseConfig([]byte(tc.Config))
+		if err == nil {
+			_, err = loader.FromConfig(config, tc.Job)
+		}
+
+		assert.EqualError(t, err, tc.ExpectedError)
+	}
+}
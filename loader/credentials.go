@@ -0,0 +1,167 @@
+package loader
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials is the username and password pair used to authenticate a stream load request.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialsProvider provides the credentials of each stream load request. Credentials is consulted before every request and Refresh is called once when Doris rejects the credentials with 401 Unauthorized.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+	Refresh(ctx context.Context) error
+}
+
+// StaticCredentialsProvider always provides the same credentials.
+type StaticCredentialsProvider struct {
+	credentials Credentials
+}
+
+// NewStaticCredentialsProvider creates a provider which always returns the given username and password.
+func NewStaticCredentialsProvider(username string, password string) *StaticCredentialsProvider {
+	return &StaticCredentialsProvider{
+		credentials: Credentials{
+			Username: username,
+			Password: password,
+		},
+	}
+}
+
+func (p *StaticCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	return p.credentials, nil
+}
+
+func (p *StaticCredentialsProvider) Refresh(ctx context.Context) error {
+	return nil
+}
+
+// EnvCredentialsProvider reads the credentials from environment variables on each request.
+type EnvCredentialsProvider struct {
+	UsernameKey string // Environment variable holding the username
+	PasswordKey string // Environment variable holding the password
+}
+
+// NewEnvCredentialsProvider creates a provider which reads the username and password from the given environment variables.
+func NewEnvCredentialsProvider(usernameKey string, passwordKey string) *EnvCredentialsProvider {
+	return &EnvCredentialsProvider{
+		UsernameKey: usernameKey,
+		PasswordKey: passwordKey,
+	}
+}
+
+func (p *EnvCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	username, ok := os.LookupEnv(p.UsernameKey)
+	if !ok {
+		return Credentials{}, ErrMissingRequiredValue(p.UsernameKey)
+	}
+
+	return Credentials{
+		Username: username,
+		Password: os.Getenv(p.PasswordKey),
+	}, nil
+}
+
+func (p *EnvCredentialsProvider) Refresh(ctx context.Context) error {
+	return nil
+}
+
+// FileCredentialsProvider reads the credentials from files, such as the ones mounted by a secret manager. The files are reloaded whenever their modification time changes or Refresh is called. Surrounding whitespace of the file content is trimmed.
+type FileCredentialsProvider struct {
+	UsernameFile string // Path of the file holding the username
+	PasswordFile string // Path of the file holding the password
+
+	mu          sync.Mutex
+	credentials Credentials
+	modTime     time.Time
+}
+
+// NewFileCredentialsProvider creates a provider which reads the username and password from the given files. It'll return an error if the files cannot be read.
+func NewFileCredentialsProvider(usernameFile string, passwordFile string) (*FileCredentialsProvider, error) {
+	p := &FileCredentialsProvider{
+		UsernameFile: usernameFile,
+		PasswordFile: passwordFile,
+	}
+
+	if err := p.Refresh(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *FileCredentialsProvider) Credentials(ctx context.Context) (Credentials, error) {
+	modTime, err := p.latestModTime()
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	p.mu.Lock()
+	changed := !modTime.Equal(p.modTime)
+	p.mu.Unlock()
+
+	if changed {
+		if err := p.Refresh(ctx); err != nil {
+			return Credentials{}, err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.credentials, nil
+}
+
+func (p *FileCredentialsProvider) Refresh(ctx context.Context) error {
+	modTime, err := p.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	username, err := os.ReadFile(p.UsernameFile)
+	if err != nil {
+		return err
+	}
+
+	password, err := os.ReadFile(p.PasswordFile)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.credentials = Credentials{
+		Username: strings.TrimSpace(string(username)),
+		Password: strings.TrimSpace(string(password)),
+	}
+	p.modTime = modTime
+
+	return nil
+}
+
+// latestModTime returns the latest modification time of the username and password files.
+func (p *FileCredentialsProvider) latestModTime() (time.Time, error) {
+	usernameInfo, err := os.Stat(p.UsernameFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	passwordInfo, err := os.Stat(p.PasswordFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if passwordInfo.ModTime().After(usernameInfo.ModTime()) {
+		return passwordInfo.ModTime(), nil
+	}
+
+	return usernameInfo.ModTime(), nil
+}
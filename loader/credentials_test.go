@@ -0,0 +1,130 @@
+package loader_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+type rotatingCredentialsProvider struct {
+	mu        sync.Mutex
+	password  string
+	next      string
+	refreshed int
+}
+
+func (p *rotatingCredentialsProvider) Credentials(ctx context.Context) (loader.Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return loader.Credentials{Username: "root", Password: p.password}, nil
+}
+
+func (p *rotatingCredentialsProvider) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.password = p.next
+	p.refreshed++
+
+	return nil
+}
+
+func TestLoadFileRefreshesCredentialsOnUnauthorized(t *testing.T) {
+	t.Log("a 401 response should refresh the credentials and retry once without consuming the retry budget")
+
+	server := doristest.NewServer(t, doristest.WithPassword("rotated"))
+	provider := &rotatingCredentialsProvider{password: "expired", next: "rotated"}
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithCredentialsProvider(provider),
+		loader.WithMaxRetry(1),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, 1, provider.refreshed)
+	assert.Len(t, server.Loads(), 2)
+}
+
+func TestLoadFileRefreshesCredentialsOnlyOnce(t *testing.T) {
+	t.Log("a 401 response after refreshing should be treated as an ordinary failure")
+
+	server := doristest.NewServer(t, doristest.WithPassword("unknown"))
+	provider := &rotatingCredentialsProvider{password: "expired", next: "still_expired"}
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithCredentialsProvider(provider),
+		loader.WithMaxRetry(2),
+		loader.WithRetryInterval(time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	_, err = ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.ErrorIs(t, err, loader.ErrUnauthorized)
+	assert.Equal(t, 1, provider.refreshed)
+	assert.Len(t, server.Loads(), 3)
+}
+
+func TestFileCredentialsProvider(t *testing.T) {
+	t.Log("file credentials provider should reload the files after they are rotated")
+
+	dir := t.TempDir()
+	usernameFile := filepath.Join(dir, "username")
+	passwordFile := filepath.Join(dir, "password")
+
+	assert.NoError(t, os.WriteFile(usernameFile, []byte("root\n"), 0o600))
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("first\n"), 0o600))
+
+	provider, err := loader.NewFileCredentialsProvider(usernameFile, passwordFile)
+	assert.NoError(t, err)
+
+	credentials, err := provider.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, loader.Credentials{Username: "root", Password: "first"}, credentials)
+
+	assert.NoError(t, os.WriteFile(passwordFile, []byte("second\n"), 0o600))
+	rotatedAt := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(passwordFile, rotatedAt, rotatedAt))
+
+	credentials, err = provider.Credentials(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, loader.Credentials{Username: "root", Password: "second"}, credentials)
+}
+
+func TestCredentialsProviderOptionConflict(t *testing.T) {
+	t.Log("credentials provider should not be combined with username or password options")
+
+	_, err := loader.NewStreamLoader(
+		[]string{"127.0.0.1:8030"},
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithCredentialsProvider(loader.NewStaticCredentialsProvider("root", "")),
+	)
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("Credentials").Error())
+
+	_, err = loader.NewStreamLoader(
+		[]string{"127.0.0.1:8030"},
+		"test_db",
+		"users",
+		loader.WithCredentialsProvider(loader.NewEnvCredentialsProvider("DORIS_USERNAME", "DORIS_PASSWORD")),
+		loader.WithPassword("changeme"),
+	)
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("Credentials").Error())
+}
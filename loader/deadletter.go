@@ -0,0 +1,151 @@
+package loader
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrorRow is a row rejected by Doris, parsed from the error report of ErrorURL.
+type ErrorRow struct {
+	Reason string `json:"reason"`  // Why the row was filtered
+	RawRow string `json:"raw_row"` // Source line of the row, empty if Doris didn't report it
+}
+
+var (
+	// Doris 2.x: "Reason: <reason>. src line [<row>]; "
+	srcLinePattern = regexp.MustCompile(`^Reason: (.*?)\.? src line \[(.*)\];?\s*$`)
+	// Doris 1.x: "Error: <reason>. Row: <row>"
+	rowPattern = regexp.MustCompile(`^Error: (.*?)\.? Row: (.*)$`)
+)
+
+// FetchErrorRows downloads the error report of ErrorURL and parses it into rejected rows. It returns nothing if the result has no ErrorURL.
+func (s StreamLoader) FetchErrorRows(
+	ctx context.Context,
+	result *StreamLoadResult,
+) ([]ErrorRow, error) {
+	if result == nil || result.ErrorURL == "" {
+		return nil, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.ErrorURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error report status: %s", res.Status)
+	}
+
+	var rows []ErrorRow
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		rows = append(rows, parseErrorRow(line))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// parseErrorRow parses a line of the error report. Unknown formats keep the whole line as the reason.
+func parseErrorRow(line string) ErrorRow {
+	for _, pattern := range []*regexp.Regexp{srcLinePattern, rowPattern} {
+		if match := pattern.FindStringSubmatch(line); match != nil {
+			return ErrorRow{Reason: match[1], RawRow: match[2]}
+		}
+	}
+
+	return ErrorRow{Reason: line}
+}
+
+// DeadLetterSink receives the rows filtered by Doris. The loader feeds it automatically when a load reports filtered rows.
+type DeadLetterSink interface {
+	WriteDeadLetters(ctx context.Context, result *StreamLoadResult, rows []ErrorRow) error
+}
+
+// DeadLetterFunc adapts a function to DeadLetterSink.
+type DeadLetterFunc func(ctx context.Context, result *StreamLoadResult, rows []ErrorRow) error
+
+func (f DeadLetterFunc) WriteDeadLetters(ctx context.Context, result *StreamLoadResult, rows []ErrorRow) error {
+	return f(ctx, result, rows)
+}
+
+// FileDeadLetterSink appends the filtered rows to a file as JSON lines, along with the label of the load and the time they were written.
+type FileDeadLetterSink struct {
+	Path string // File the rows are appended to
+
+	mu sync.Mutex
+}
+
+// NewFileDeadLetterSink creates a sink appending the filtered rows to the file.
+func NewFileDeadLetterSink(path string) *FileDeadLetterSink {
+	return &FileDeadLetterSink{Path: path}
+}
+
+func (f *FileDeadLetterSink) WriteDeadLetters(ctx context.Context, result *StreamLoadResult, rows []ErrorRow) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	now := time.Now()
+
+	for _, row := range rows {
+		err := encoder.Encode(struct {
+			Label string    `json:"label"`
+			Time  time.Time `json:"time"`
+			ErrorRow
+		}{
+			Label:    result.Label,
+			Time:     now,
+			ErrorRow: row,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// deliverDeadLetters feeds the rows filtered by the load to the DeadLetterSink.
+func (s StreamLoader) deliverDeadLetters(ctx context.Context, result *StreamLoadResult) error {
+	if s.DeadLetterSink == nil || result.NumberFilteredRows == 0 || result.ErrorURL == "" {
+		return nil
+	}
+
+	rows, err := s.FetchErrorRows(ctx, result)
+	if err != nil {
+		return err
+	}
+
+	return s.DeadLetterSink.WriteDeadLetters(ctx, result, rows)
+}
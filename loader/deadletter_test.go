@@ -0,0 +1,111 @@
+package loader_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFilteringFe(t *testing.T) *doristest.Server {
+	t.Helper()
+
+	return doristest.NewServer(t,
+		doristest.WithRoute("/api/_load_error_log", doristest.Response{Body: "Reason: column(age) value is incorrect while convert to INT. src line [{\"name\": 10, \"age\": \"Kimi\"}]; \n" +
+			"Error: Value count does not match column count. Expect 2, but got 3. Row: a,b,c\n" +
+			"something unexpected\n",
+		}),
+		doristest.WithResponder(func(load doristest.Load) doristest.Response {
+			return doristest.Response{Body: fmt.Sprintf(`{
+				"Status": "Success",
+				"Label": "label_a",
+				"NumberTotalRows": 4,
+				"NumberLoadedRows": 1,
+				"NumberFilteredRows": 3,
+				"ErrorURL": "http://%s/api/_load_error_log?file=error_log_a"
+			}`, load.Host)}
+		}),
+	)
+}
+
+func TestFetchErrorRows(t *testing.T) {
+	t.Log("the error report should be parsed into reasons and raw rows")
+
+	server := newFilteringFe(t)
+
+	ld, err := loader.NewStreamLoader([]string{server.Host()}, "test_db", "users")
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFile(context.Background(), "../manifest/test/users_wrong_data.json")
+	assert.NoError(t, err)
+
+	rows, err := ld.FetchErrorRows(context.Background(), result)
+	assert.NoError(t, err)
+	assert.Equal(t, []loader.ErrorRow{
+		{Reason: "column(age) value is incorrect while convert to INT", RawRow: `{"name": 10, "age": "Kimi"}`},
+		{Reason: "Value count does not match column count. Expect 2, but got 3", RawRow: "a,b,c"},
+		{Reason: "something unexpected"},
+	}, rows)
+
+	rows, err = ld.FetchErrorRows(context.Background(), &loader.StreamLoadResult{Status: "Success"})
+	assert.NoError(t, err)
+	assert.Empty(t, rows)
+}
+
+func TestDeadLetterSink(t *testing.T) {
+	t.Log("filtered rows should be delivered to the dead letter sink automatically")
+
+	server := newFilteringFe(t)
+	path := filepath.Join(t.TempDir(), "dead_letters.jsonl")
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithDeadLetterSink(loader.NewFileDeadLetterSink(path)),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFile(context.Background(), "../manifest/test/users_wrong_data.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.NumberFilteredRows)
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line map[string]any
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+
+	assert.Len(t, lines, 3)
+	assert.Equal(t, "label_a", lines[0]["label"])
+	assert.Equal(t, "a,b,c", lines[1]["raw_row"])
+
+	t.Log("a failing sink should be reported along with the result")
+
+	ld, err = loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithDeadLetterSink(loader.DeadLetterFunc(func(ctx context.Context, result *loader.StreamLoadResult, rows []loader.ErrorRow) error {
+			return os.ErrPermission
+		})),
+	)
+	assert.NoError(t, err)
+
+	result, err = ld.LoadFile(context.Background(), "../manifest/test/users_wrong_data.json")
+	assert.ErrorIs(t, err, os.ErrPermission)
+	assert.NotNil(t, result)
+}
@@ -0,0 +1,165 @@
+package loader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// FileResult is the result of stream loading one file of LoadDir or LoadGlob.
+type FileResult struct {
+	Filename string            // Loaded file
+	Label    string            // Stream load label of the file
+	Skipped  bool              // Whether the file was skipped since the checkpoint records it loaded
+	Result   *StreamLoadResult // Stream load result, nil if skipped or the request failed
+	Err      error             // Request error, nil if the request was sent
+}
+
+// IsSuccess reports whether the file was loaded, by this call or a previous one.
+func (f FileResult) IsSuccess() bool {
+	if f.Skipped {
+		return true
+	}
+
+	return f.Err == nil && f.Result != nil && f.Result.IsLoaded()
+}
+
+// BatchLoadResult is the result of LoadDir and LoadGlob.
+type BatchLoadResult struct {
+	Files []FileResult // Result of every matched file, sorted by file name
+}
+
+// IsSuccess reports whether every file was loaded.
+func (r BatchLoadResult) IsSuccess() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns the files which were not loaded.
+func (r BatchLoadResult) Failed() []FileResult {
+	var failed []FileResult
+	for _, file := range r.Files {
+		if !file.IsSuccess() {
+			failed = append(failed, file)
+		}
+	}
+
+	return failed
+}
+
+// LoadDir stream loads every regular file directly in the directory. See LoadGlob.
+func (s StreamLoader) LoadDir(
+	ctx context.Context,
+	dir string,
+) (*BatchLoadResult, error) {
+	return s.LoadGlob(ctx, filepath.Join(dir, "*"))
+}
+
+// LoadGlob stream loads every regular file matching the pattern by Concurrency workers. Each file is loaded with a label made of the loader label, if any, and a digest of its path, size and modification time.
+//
+// If a checkpoint file is set by WithCheckpointFile, the label and outcome of each file is recorded in it, and the files recorded as loaded with the same label are skipped. Rerunning after a partial failure therefore loads only the failed, new or modified files.
+func (s StreamLoader) LoadGlob(
+	ctx context.Context,
+	pattern string,
+) (*BatchLoadResult, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	var cp *checkpoint
+	if s.CheckpointFile != "" {
+		if cp, err = openCheckpoint(s.CheckpointFile); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &BatchLoadResult{}
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		if s.CheckpointFile != "" && checkpointKey(match) == checkpointKey(s.CheckpointFile) {
+			continue
+		}
+
+		file := FileResult{
+			Filename: match,
+			Label:    s.batchFileLabel(match, info),
+		}
+		file.Skipped = cp != nil && cp.isLoaded(match, file.Label)
+
+		result.Files = append(result.Files, file)
+	}
+
+	pending := make(chan int)
+	errs := make(chan error, len(result.Files))
+	var wg sync.WaitGroup
+
+	for i := 0; i < max(s.Concurrency, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range pending {
+				file := &result.Files[index]
+				file.Result, file.Err = s.loadFile(ctx, file.Filename, file.Label)
+
+				if cp != nil {
+					if err := cp.record(file.Filename, file.Label, file.Result, file.Err); err != nil {
+						errs <- err
+					}
+				}
+			}
+		}()
+	}
+
+	for index, file := range result.Files {
+		if !file.Skipped {
+			pending <- index
+		}
+	}
+	close(pending)
+	wg.Wait()
+	close(errs)
+
+	// A checkpoint which cannot be written would make the next run load the files again.
+	if err := <-errs; err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// loadFile stream loads a file with the label.
+func (s StreamLoader) loadFile(
+	ctx context.Context,
+	filename string,
+	label string,
+) (*StreamLoadResult, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return s.load(ctx, file, filename, label)
+}
+
+// batchFileLabel returns the label of a file loaded by LoadGlob.
+func (s StreamLoader) batchFileLabel(filename string, info os.FileInfo) string {
+	if label, ok := s.Header["label"]; ok {
+		return fmt.Sprintf("%v_%s", label, fileDigest(filename, info))
+	}
+
+	return "doris_loader_" + fileDigest(filename, info)
+}
@@ -0,0 +1,80 @@
+package loader_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDirWithCheckpoint(t *testing.T) {
+	t.Log("a rerun should skip the files recorded as loaded in the checkpoint and retry only the failures")
+
+	var mu sync.Mutex
+	failed := false
+	server := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if strings.Contains(string(load.Payload), "broken") && !failed {
+			failed = true
+			return doristest.Response{Body: `{"Status": "Fail", "Message": "injected failure"}`}
+		}
+
+		return doristest.Success(load)
+	}))
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.json": `{"name": "a", "age": 1}`,
+		"b.json": `{"name": "broken", "age": 2}`,
+		"c.json": `{"name": "c", "age": 3}`,
+	} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+	checkpointFile := filepath.Join(dir, "checkpoint.json")
+
+	newLoader := func() *loader.StreamLoader {
+		ld, err := loader.NewStreamLoader(
+			[]string{server.Host()},
+			"test_db",
+			"users",
+			loader.WithCheckpointFile(checkpointFile),
+			loader.WithMaxRetry(1),
+		)
+		assert.NoError(t, err)
+
+		return ld
+	}
+
+	result, err := newLoader().LoadDir(context.Background(), dir)
+	assert.NoError(t, err)
+	assert.Len(t, result.Files, 3)
+	assert.Len(t, result.Failed(), 1)
+	assert.Equal(t, filepath.Join(dir, "b.json"), result.Failed()[0].Filename)
+	assert.Len(t, server.Loads(), 3)
+
+	data, err := os.ReadFile(checkpointFile)
+	assert.NoError(t, err)
+	var recorded struct {
+		Files map[string]loader.CheckpointEntry `json:"files"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &recorded))
+	assert.Len(t, recorded.Files, 3)
+	assert.Equal(t, "Fail", recorded.Files[filepath.Join(dir, "b.json")].Status)
+
+	result, err = newLoader().LoadGlob(context.Background(), filepath.Join(dir, "*.json"))
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.True(t, result.Files[0].Skipped)
+	assert.False(t, result.Files[1].Skipped)
+	assert.True(t, result.Files[2].Skipped)
+	assert.Len(t, server.Loads(), 4)
+}
@@ -0,0 +1,127 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// backendsResponse is the response body of the FE backends API.
+type backendsResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Backends []struct {
+			Ip       string `json:"ip"`
+			HttpPort int    `json:"http_port"`
+			IsAlive  bool   `json:"is_alive"`
+		} `json:"backends"`
+	} `json:"data"`
+}
+
+// startBeDiscovery discovers the BE nodes every BeDiscoveryInterval until the context is done.
+func (s StreamLoader) startBeDiscovery(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.BeDiscoveryInterval)
+		defer ticker.Stop()
+
+		for {
+			_ = s.discoverBackends(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// discoverBackends fetches the alive BE nodes from the FE nodes and replaces the BE node pool with them. The FE nodes are tried in turn until one of them answers.
+func (s StreamLoader) discoverBackends(ctx context.Context) error {
+	var err error
+
+	for _, feNode := range s.frontends().Next() {
+		var backends []string
+		backends, err = s.fetchBackends(ctx, feNode)
+		if err != nil {
+			s.log(ctx, slog.LevelWarn, "backend discovery failed", slog.String("fe", feNode), slog.Any("error", err))
+			continue
+		}
+
+		s.log(ctx, slog.LevelDebug, "backend nodes discovered", slog.String("fe", feNode), slog.Any("backends", backends))
+		s.backends().SetNodes(backends)
+
+		return nil
+	}
+
+	if err == nil {
+		err = ErrMissingRequiredValue("FeNodes")
+	}
+
+	return err
+}
+
+// fetchBackends returns the HTTP endpoints of the alive BE nodes known by the FE node.
+func (s StreamLoader) fetchBackends(ctx context.Context, feNode string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.BeDiscoveryInterval)
+	defer cancel()
+
+	url := fmt.Sprintf("%s://%s/api/backends?is_alive=true", s.Protocol, feNode)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(credentials.Username, credentials.Password)
+
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("backends status: %s", res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response backendsResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	if response.Code != 0 {
+		return nil, fmt.Errorf("backends code=%d msg=%s", response.Code, response.Msg)
+	}
+
+	backends := make([]string, 0, len(response.Data.Backends))
+	for _, backend := range response.Data.Backends {
+		if !backend.IsAlive {
+			continue
+		}
+
+		backends = append(backends, net.JoinHostPort(backend.Ip, strconv.Itoa(backend.HttpPort)))
+	}
+
+	if len(backends) == 0 {
+		return nil, ErrNoAvailableBeNode
+	}
+
+	return backends, nil
+}
@@ -0,0 +1,67 @@
+package loader_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBeDiscovery(t *testing.T) {
+	t.Log("alive BE nodes discovered from the FE should receive the redirected loads")
+
+	be := doristest.NewServer(t)
+	beHost, bePort, err := net.SplitHostPort(be.Host())
+	assert.NoError(t, err)
+
+	fe := doristest.NewServer(t,
+		doristest.WithRedirect("http://backend.invalid/"),
+		doristest.WithRoute("/api/backends", doristest.Response{Body: fmt.Sprintf(`{
+			"msg": "success",
+			"code": 0,
+			"data": {
+				"backends": [
+					{"ip": "%s", "http_port": %s, "is_alive": true},
+					{"ip": "10.0.0.99", "http_port": 8040, "is_alive": false}
+				]
+			},
+			"count": 0
+		}`, beHost, bePort)}),
+	)
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe.Host()},
+		"test_db",
+		"users",
+		loader.WithBeDiscovery(time.Hour),
+	)
+	assert.NoError(t, err)
+	defer ld.Close()
+
+	result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Len(t, be.Loads(), 1)
+
+	status := ld.BeStatus()
+	assert.Len(t, status, 1)
+	assert.Equal(t, be.Host(), status[0].Addr)
+}
+
+func TestBeDiscoveryOptionConflict(t *testing.T) {
+	t.Log("BE discovery should not be combined with static BE nodes")
+
+	_, err := loader.NewStreamLoader(
+		[]string{"127.0.0.1:8030"},
+		"test_db",
+		"users",
+		loader.WithBeNodes([]string{"127.0.0.1:8040"}),
+		loader.WithBeDiscovery(time.Minute),
+	)
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("BeNodes").Error())
+}
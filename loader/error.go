@@ -1,6 +1,7 @@
 package loader
 
 import (
+	"errors"
 	"fmt"
 )
 
@@ -17,4 +18,29 @@ var (
 	ErrMissingRequiredValue = func(value any) error {
 		return fmt.Errorf("missing required value: %v", value)
 	}
+	ErrSchemaMismatch = func(field string, reason string) error {
+		return fmt.Errorf("schema mismatch: %s: %s", field, reason)
+	}
+	ErrDeadLetter = func(err error) error {
+		return fmt.Errorf("dead letter delivery failed: %w", err)
+	}
+	ErrInvalidConfig = func(path string, err error) error {
+		return fmt.Errorf("invalid config: %s: %w", path, err)
+	}
+	ErrInvalidDSN = func(param string, err error) error {
+		return fmt.Errorf("invalid dsn: %s: %w", param, err)
+	}
+	ErrClientOption = func(field string) error {
+		return fmt.Errorf("option only applies to the client: %s", field)
+	}
+)
+
+var (
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrNoAvailableBeNode  = errors.New("no available backend node")
+	ErrQueueFull          = errors.New("queue is full")
+	ErrLoaderClosed       = errors.New("loader is closed")
+	ErrNoValidRows        = errors.New("no valid rows")
+	ErrFanOutNotSatisfied = errors.New("fan-out mode not satisfied")
+	ErrSchemaValidation   = errors.New("schema validation failed")
 )
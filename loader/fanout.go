@@ -0,0 +1,208 @@
+package loader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/enum"
+	"github.com/raaaaaaaay86/doris-loader/enum/fanoutmode"
+)
+
+// ClusterResult is the result of stream loading the payload of FanOutLoader to one cluster.
+type ClusterResult struct {
+	Cluster string            // Name of the cluster
+	Result  *StreamLoadResult // Stream load result, nil if the request failed
+	Err     error             // Request error, nil if the request was sent
+}
+
+// IsSuccess reports whether the payload was loaded to the cluster, including by a previous attempt with the same label.
+func (c ClusterResult) IsSuccess() bool {
+	return c.Err == nil && c.Result != nil && c.Result.IsLoaded()
+}
+
+// FanOutResult is the result of FanOutLoader.Load and FanOutLoader.Redrive.
+type FanOutResult struct {
+	Label    string          // Stream load label shared by the clusters
+	Mode     fanoutmode.Enum // Mode deciding whether the load succeeded
+	Clusters []ClusterResult // Result of every cluster, sorted by cluster name
+}
+
+// IsSuccess reports whether enough clusters loaded the payload for the Mode: every cluster for All, a majority for Quorum and at least one for BestEffort.
+func (r FanOutResult) IsSuccess() bool {
+	succeeded := len(r.Clusters) - len(r.Failed())
+
+	switch r.Mode {
+	case fanoutmode.Quorum:
+		return succeeded > len(r.Clusters)/2
+	case fanoutmode.BestEffort:
+		return succeeded > 0
+	default:
+		return succeeded == len(r.Clusters)
+	}
+}
+
+// Failed returns the clusters which didn't load the payload.
+func (r FanOutResult) Failed() []ClusterResult {
+	var failed []ClusterResult
+	for _, cluster := range r.Clusters {
+		if !cluster.IsSuccess() {
+			failed = append(failed, cluster)
+		}
+	}
+
+	return failed
+}
+
+// FanOutLoader sends the same payload with the same label to several clusters concurrently, e.g. to dual-write to a disaster recovery cluster. Doris label deduplication makes it safe to send the payload again to the clusters which failed with Redrive.
+type FanOutLoader struct {
+	Mode fanoutmode.Enum // Mode deciding whether a load succeeded (default: All)
+
+	clusters map[string]*StreamLoader
+}
+
+type FanOutLoaderOption func(*FanOutLoader) error
+
+// NewFanOutLoader creates a fan-out loader sending to the stream loaders of the clusters, keyed by the cluster names used in FanOutResult.
+func NewFanOutLoader(
+	clusters map[string]*StreamLoader,
+	options ...FanOutLoaderOption,
+) (*FanOutLoader, error) {
+	if len(clusters) == 0 {
+		return nil, ErrMissingRequiredValue("Clusters")
+	}
+
+	for name, loader := range clusters {
+		if loader == nil {
+			return nil, ErrMissingRequiredValue(fmt.Sprintf("StreamLoader of %s", name))
+		}
+	}
+
+	fanOut := FanOutLoader{clusters: clusters}
+
+	for _, option := range options {
+		if err := option(&fanOut); err != nil {
+			return nil, err
+		}
+	}
+
+	if enum.IsZero(fanOut.Mode) {
+		if err := WithFanOutMode(fanoutmode.All)(&fanOut); err != nil {
+			return nil, err
+		}
+	}
+
+	return &fanOut, nil
+}
+
+// Load stream loads the payload to every cluster concurrently with the label, and waits for all of them. An empty label gets a generated one, since the clusters must share it for Redrive to be idempotent.
+//
+// The result of every cluster is reported in FanOutResult. If the Mode isn't satisfied, the result is returned along with an error wrapped by ErrFanOutNotSatisfied; pass it to Redrive to load the payload again to the failed clusters only.
+func (f *FanOutLoader) Load(
+	ctx context.Context,
+	label string,
+	payload []byte,
+) (*FanOutResult, error) {
+	if label == "" {
+		label = fmt.Sprintf("fanout_%d_%08x", time.Now().UnixNano(), rand.Uint32())
+	}
+
+	result := &FanOutResult{
+		Label: label,
+		Mode:  f.Mode,
+	}
+	for _, name := range slices.Sorted(maps.Keys(f.clusters)) {
+		result.Clusters = append(result.Clusters, ClusterResult{Cluster: name})
+	}
+
+	return f.load(ctx, payload, result)
+}
+
+// Redrive loads the payload again with the label of the previous result, to the clusters which failed only. The payload must be the one of the previous load.
+func (f *FanOutLoader) Redrive(
+	ctx context.Context,
+	previous *FanOutResult,
+	payload []byte,
+) (*FanOutResult, error) {
+	result := &FanOutResult{
+		Label:    previous.Label,
+		Mode:     f.Mode,
+		Clusters: append([]ClusterResult(nil), previous.Clusters...),
+	}
+
+	return f.load(ctx, payload, result)
+}
+
+// load loads the payload to the clusters of the result which didn't load it yet, and checks the Mode.
+func (f *FanOutLoader) load(
+	ctx context.Context,
+	payload []byte,
+	result *FanOutResult,
+) (*FanOutResult, error) {
+	var wg sync.WaitGroup
+	for i, cluster := range result.Clusters {
+		if cluster.IsSuccess() {
+			continue
+		}
+
+		loader, ok := f.clusters[cluster.Cluster]
+		if !ok {
+			result.Clusters[i].Err = ErrMissingRequiredValue(fmt.Sprintf("StreamLoader of %s", cluster.Cluster))
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			loadResult, err := loader.load(ctx, bytes.NewReader(payload), "", result.Label)
+			result.Clusters[i].Result = loadResult
+			result.Clusters[i].Err = err
+		}()
+	}
+	wg.Wait()
+
+	if result.IsSuccess() {
+		return result, nil
+	}
+
+	var errs []error
+	for _, cluster := range result.Failed() {
+		err := cluster.Err
+		if err == nil && cluster.Result != nil {
+			err = cluster.Result.Error()
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", cluster.Cluster, err))
+	}
+
+	return result, fmt.Errorf("%w (%s): %w", ErrFanOutNotSatisfied, f.Mode, errors.Join(errs...))
+}
+
+// WithFanOutMode sets the mode deciding whether a load succeeded. It'll return an error if there has any mode set before or provided an unexpected fanoutmode.Enum.
+func WithFanOutMode(mode fanoutmode.Enum) FanOutLoaderOption {
+	return func(loader *FanOutLoader) error {
+		if !enum.IsZero(loader.Mode) && loader.Mode != mode {
+			return ErrAmbiguousOption("Mode")
+		}
+
+		switch mode {
+		case fanoutmode.All, fanoutmode.Quorum, fanoutmode.BestEffort:
+			loader.Mode = mode
+		default:
+			if enum.IsZero(mode) {
+				return ErrZeroValueOption("Mode")
+			}
+
+			return ErrUnsupportValue(mode)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,170 @@
+package loader_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/enum/fanoutmode"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var fanOutUsers = loader.TableSchema{
+	Database: "test_db",
+	Table:    "users",
+	Columns: []loader.Column{
+		{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true},
+	},
+}
+
+// newFanOutCluster starts a fake cluster failing the first failures stream loads.
+func newFanOutCluster(t *testing.T, failures int) (*dorisfake.Server, *loader.StreamLoader) {
+	t.Helper()
+
+	plan := dorisfake.NewFaultPlan()
+	for i := 0; i < failures; i++ {
+		plan.OnFe(dorisfake.StatusError(http.StatusServiceUnavailable))
+	}
+
+	server, err := dorisfake.NewServer(dorisfake.WithTable(fanOutUsers), dorisfake.WithFaultPlan(plan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	ld, err := loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithMaxRetry(1),
+		loader.WithRetryInterval(time.Millisecond),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return server, ld
+}
+
+func TestFanOutLoader(t *testing.T) {
+	t.Log("a failed cluster should fail the All mode and be the only cluster loaded again by Redrive")
+
+	primary, primaryLoader := newFanOutCluster(t, 0)
+	dr, drLoader := newFanOutCluster(t, 1)
+
+	fanOut, err := loader.NewFanOutLoader(map[string]*loader.StreamLoader{
+		"primary": primaryLoader,
+		"dr":      drLoader,
+	})
+	assert.NoError(t, err)
+
+	payload := []byte(`{"name": "John Doe"}`)
+	result, err := fanOut.Load(context.Background(), "", payload)
+	assert.ErrorIs(t, err, loader.ErrFanOutNotSatisfied)
+	assert.NotEmpty(t, result.Label)
+	assert.False(t, result.IsSuccess())
+	if assert.Len(t, result.Failed(), 1) {
+		assert.Equal(t, "dr", result.Failed()[0].Cluster)
+	}
+	assert.Equal(t, []string{"dr", "primary"}, []string{result.Clusters[0].Cluster, result.Clusters[1].Cluster})
+
+	result, err = fanOut.Redrive(context.Background(), result, payload)
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+
+	for _, server := range []*dorisfake.Server{primary, dr} {
+		loads := server.Loads()
+		if assert.Len(t, loads, 1) {
+			assert.Equal(t, result.Label, loads[0].Result.Label)
+		}
+		assert.Len(t, server.Rows("test_db", "users"), 1)
+	}
+}
+
+func TestFanOutLoaderModes(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Mode            fanoutmode.Enum
+		Failures        []int
+		ExpectSuccess   bool
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "All should succeed when every cluster succeeds",
+			Mode:            fanoutmode.All,
+			Failures:        []int{0, 0, 0},
+			ExpectSuccess:   true,
+		},
+		{
+			TestDescription: "Quorum should succeed when a majority of clusters succeeds",
+			Mode:            fanoutmode.Quorum,
+			Failures:        []int{0, 0, 1},
+			ExpectSuccess:   true,
+		},
+		{
+			TestDescription: "Quorum should fail when half of the clusters fail",
+			Mode:            fanoutmode.Quorum,
+			Failures:        []int{0, 1},
+			ExpectSuccess:   false,
+		},
+		{
+			TestDescription: "BestEffort should succeed when any cluster succeeds",
+			Mode:            fanoutmode.BestEffort,
+			Failures:        []int{1, 1, 0},
+			ExpectSuccess:   true,
+		},
+		{
+			TestDescription: "BestEffort should fail when every cluster fails",
+			Mode:            fanoutmode.BestEffort,
+			Failures:        []int{1, 1},
+			ExpectSuccess:   false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		clusters := map[string]*loader.StreamLoader{}
+		for i, failures := range tc.Failures {
+			_, ld := newFanOutCluster(t, failures)
+			clusters[string(rune('a'+i))] = ld
+		}
+
+		fanOut, err := loader.NewFanOutLoader(clusters, loader.WithFanOutMode(tc.Mode))
+		assert.NoError(t, err)
+
+		result, err := fanOut.Load(context.Background(), "fanout_label", []byte(`{"name": "John Doe"}`))
+		assert.Equal(t, tc.ExpectSuccess, result.IsSuccess())
+		assert.Len(t, result.Clusters, len(tc.Failures))
+		if tc.ExpectSuccess {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorIs(t, err, loader.ErrFanOutNotSatisfied)
+		}
+	}
+}
+
+func TestNewFanOutLoader(t *testing.T) {
+	t.Log("missing clusters or invalid modes should be rejected")
+
+	_, err := loader.NewFanOutLoader(nil)
+	assert.ErrorContains(t, err, loader.ErrMissingRequiredValue("Clusters").Error())
+
+	_, ld := newFanOutCluster(t, 0)
+	clusters := map[string]*loader.StreamLoader{"primary": ld}
+
+	_, err = loader.NewFanOutLoader(clusters, loader.WithFanOutMode("majority"))
+	assert.ErrorContains(t, err, loader.ErrUnsupportValue("majority").Error())
+
+	_, err = loader.NewFanOutLoader(clusters, loader.WithFanOutMode(fanoutmode.All), loader.WithFanOutMode(fanoutmode.Quorum))
+	assert.ErrorContains(t, err, loader.ErrAmbiguousOption("Mode").Error())
+
+	fanOut, err := loader.NewFanOutLoader(clusters)
+	assert.NoError(t, err)
+	assert.Equal(t, fanoutmode.All, fanOut.Mode)
+}
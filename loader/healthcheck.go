@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// healthResponse is the response body of the FE health API.
+type healthResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+// FeStatus returns the cached health state of the FE nodes.
+func (s StreamLoader) FeStatus() []NodeStatus {
+	return s.frontends().Status()
+}
+
+// Close stops the background health check and BE discovery. The loader can still be used after closed, relying on the failures observed by requests and the last discovered BE nodes only.
+func (s StreamLoader) Close() error {
+	if s.stopBackground != nil {
+		s.stopBackground()
+	}
+
+	return nil
+}
+
+// frontends returns the FE node pool. A loader not built by NewStreamLoader gets a stateless pool of FeNodes.
+func (s StreamLoader) frontends() *NodePool {
+	if s.fePool == nil {
+		return NewNodePool(s.FeNodes, s.NodeCooldown)
+	}
+
+	return s.fePool
+}
+
+// startHealthCheck probes the FE nodes every HealthCheckInterval until the context is done.
+func (s StreamLoader) startHealthCheck(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.HealthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			s.checkFeHealth(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// checkFeHealth probes every FE node once and updates its health state.
+func (s StreamLoader) checkFeHealth(ctx context.Context) {
+	pool := s.frontends()
+
+	for _, status := range pool.Status() {
+		if err := s.probeFe(ctx, status.Addr); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			s.log(ctx, slog.LevelWarn, "frontend node failed health check", slog.String("fe", status.Addr), slog.Any("error", err))
+			pool.MarkDown(status.Addr, err)
+			continue
+		}
+
+		pool.MarkUp(status.Addr)
+	}
+}
+
+// probeFe calls the health API of the FE node. It'll return an error if the node is unreachable or reports itself unhealthy.
+func (s StreamLoader) probeFe(ctx context.Context, feNode string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.HealthCheckInterval)
+	defer cancel()
+
+	url := fmt.Sprintf("%s://%s/api/health", s.Protocol, feNode)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := s.credentials(ctx)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(credentials.Username, credentials.Password)
+
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check status: %s", res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var health healthResponse
+	if err := json.Unmarshal(data, &health); err == nil && health.Code != 0 {
+		return fmt.Errorf("health check code=%d msg=%s", health.Code, health.Msg)
+	}
+
+	return nil
+}
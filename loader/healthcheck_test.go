@@ -0,0 +1,113 @@
+package loader_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFe(t *testing.T, healthy bool) *doristest.Server {
+	t.Helper()
+
+	health := doristest.Response{Body: `{"msg": "success", "code": 0}`}
+	if !healthy {
+		health = doristest.Response{Status: http.StatusServiceUnavailable}
+	}
+
+	return doristest.NewServer(t, doristest.WithRoute("/api/health", health))
+}
+
+func TestHealthCheckMarksUnhealthyFeDown(t *testing.T) {
+	t.Log("background health check should mark unhealthy FE nodes down and loads should prefer healthy ones")
+
+	unhealthy := newFe(t, false)
+	healthy := newFe(t, true)
+
+	ld, err := loader.NewStreamLoader(
+		[]string{unhealthy.Host(), healthy.Host()},
+		"test_db",
+		"users",
+		loader.WithHealthCheck(10*time.Millisecond),
+	)
+	assert.NoError(t, err)
+	defer ld.Close()
+
+	assert.Eventually(t, func() bool {
+		status := ld.FeStatus()
+		return !status[0].Healthy && status[1].Healthy
+	}, time.Second, 5*time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+		assert.NoError(t, err)
+		assert.True(t, result.IsSuccess())
+	}
+
+	assert.Empty(t, unhealthy.Loads())
+	assert.Len(t, healthy.Loads(), 4)
+}
+
+func TestLoadFileSkipsFailedFe(t *testing.T) {
+	t.Log("a FE node failed a request should be tried only after the healthy ones")
+
+	healthy := newFe(t, true)
+	dead := doristest.NewServer(t)
+	deadHost := dead.Host()
+	dead.Close()
+
+	ld, err := loader.NewStreamLoader(
+		[]string{deadHost, healthy.Host()},
+		"test_db",
+		"users",
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithNodeCooldown(time.Minute),
+	)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		result, err := ld.LoadFile(context.Background(), "../manifest/test/users.json")
+		assert.NoError(t, err)
+		assert.True(t, result.IsSuccess())
+	}
+
+	assert.Len(t, healthy.Loads(), 3)
+
+	status := ld.FeStatus()
+	assert.False(t, status[0].Healthy)
+	assert.True(t, status[1].Healthy)
+}
+
+func TestLoadFileKeepsFeAfterFailedRedirect(t *testing.T) {
+	t.Log("a BE node failing the redirect of the FE should not mark the FE node down when no BE nodes are configured")
+
+	server, err := dorisfake.NewServer(dorisfake.WithTable(loader.TableSchema{
+		Database: "test_db",
+		Table:    "users",
+		Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true}},
+	}))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	// The FE still redirects to the only BE node while it's offline.
+	assert.NoError(t, server.SetOffline(server.BeNodes()[0], true))
+
+	ld, err := loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithMaxRetry(1),
+	)
+	assert.NoError(t, err)
+
+	_, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.ErrorContains(t, err, server.BeNodes()[0])
+	assert.True(t, ld.FeStatus()[0].Healthy)
+}
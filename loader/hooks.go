@@ -0,0 +1,93 @@
+package loader
+
+import (
+	"context"
+	"net/http"
+)
+
+// Hooks are callbacks on the lifecycle of loads, such as updating job tables or paging on failures. Unset callbacks are skipped. They're called synchronously, so they should not block.
+type Hooks struct {
+	OnAttempt      func(ctx context.Context, event HookEvent) // Called after each attempt with its result or error
+	OnRetry        func(ctx context.Context, event HookEvent) // Called after an attempt failed and before it's retried
+	OnSuccess      func(ctx context.Context, event HookEvent) // Called once a load succeeded, including by a previous attempt with the same label
+	OnFailure      func(ctx context.Context, event HookEvent) // Called once a load failed with an error or a failed status
+	OnFilteredRows func(ctx context.Context, event HookEvent) // Called once a load finished with rows filtered by Doris
+}
+
+// HookEvent describes the load passed to Hooks.
+type HookEvent struct {
+	Label   string            // Stream load label, the one generated by Doris if no label was sent
+	Source  string            // Loaded file, with the chunk index for LoadFileParallel. Empty for LoadReader
+	Attempt int               // 1-based attempt number, the last one for the events of a load
+	Result  *StreamLoadResult // Stream load result, nil if the request failed
+	Err     error             // Request error, nil if a result was received
+}
+
+// hooks returns the Hooks of the loader, which are all unset if there's none.
+func (s StreamLoader) hooks() Hooks {
+	if s.Hooks == nil {
+		return Hooks{}
+	}
+
+	return *s.Hooks
+}
+
+// hook calls the callback, if any, with the event of the request.
+func (s StreamLoader) hook(
+	ctx context.Context,
+	callback func(ctx context.Context, event HookEvent),
+	req *http.Request,
+	result *StreamLoadResult,
+	err error,
+) {
+	if callback == nil {
+		return
+	}
+
+	state := loadStateFrom(ctx)
+	event := HookEvent{
+		Label:   req.Header.Get("label"),
+		Source:  state.source,
+		Attempt: state.attempt,
+		Result:  result,
+		Err:     err,
+	}
+
+	if event.Label == "" && result != nil {
+		event.Label = result.Label
+	}
+
+	callback(ctx, event)
+}
+
+// loadHooks is the built-in middleware calling OnSuccess, OnFailure and OnFilteredRows.
+func (s StreamLoader) loadHooks(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		result, err := next(req)
+
+		ctx := req.Context()
+		hooks := s.hooks()
+
+		if err == nil && result.IsLoaded() {
+			s.hook(ctx, hooks.OnSuccess, req, result, nil)
+		} else {
+			s.hook(ctx, hooks.OnFailure, req, result, err)
+		}
+
+		if result != nil && result.NumberFilteredRows > 0 {
+			s.hook(ctx, hooks.OnFilteredRows, req, result, nil)
+		}
+
+		return result, err
+	}
+}
+
+// attemptHooks is the built-in middleware calling OnAttempt.
+func (s StreamLoader) attemptHooks(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		result, err := next(req)
+		s.hook(req.Context(), s.hooks().OnAttempt, req, result, err)
+
+		return result, err
+	}
+}
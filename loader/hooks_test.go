@@ -0,0 +1,152 @@
+package loader_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHooks(t *testing.T) {
+	t.Log("hooks should receive the label, source, attempt number and result of the load events")
+
+	attempts := 0
+	server := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		attempts++
+		if attempts == 1 {
+			return doristest.Response{Status: http.StatusServiceUnavailable}
+		}
+
+		return doristest.Response{Body: `{"Label": "hook_label", "Status": "Success", "NumberTotalRows": 2, "NumberLoadedRows": 1, "NumberFilteredRows": 1}`}
+	}))
+
+	filename := filepath.Join(t.TempDir(), "users.json")
+	assert.NoError(t, os.WriteFile(filename, []byte(`{"name": "John Doe"}`), 0o644))
+
+	events := map[string][]loader.HookEvent{}
+	record := func(name string) func(ctx context.Context, event loader.HookEvent) {
+		return func(ctx context.Context, event loader.HookEvent) {
+			events[name] = append(events[name], event)
+		}
+	}
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithHeader(map[string]any{"label": "hook_label"}),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithHooks(loader.Hooks{
+			OnAttempt:      record("attempt"),
+			OnRetry:        record("retry"),
+			OnSuccess:      record("success"),
+			OnFailure:      record("failure"),
+			OnFilteredRows: record("filtered"),
+		}),
+	)
+	assert.NoError(t, err)
+
+	_, err = ld.LoadFile(context.Background(), filename)
+	assert.NoError(t, err)
+
+	assert.Len(t, events["attempt"], 2)
+	assert.Len(t, events["retry"], 1)
+	assert.Len(t, events["success"], 1)
+	assert.Len(t, events["failure"], 0)
+	assert.Len(t, events["filtered"], 1)
+	if t.Failed() {
+		return
+	}
+
+	for _, event := range append(events["attempt"], events["retry"]...) {
+		assert.Equal(t, "hook_label", event.Label)
+		assert.Equal(t, filename, event.Source)
+	}
+	assert.Equal(t, 1, events["attempt"][0].Attempt)
+	assert.Error(t, events["attempt"][0].Err)
+	assert.Equal(t, 2, events["attempt"][1].Attempt)
+	assert.Equal(t, 1, events["retry"][0].Attempt)
+	assert.Equal(t, 2, events["success"][0].Attempt)
+	assert.Equal(t, filename, events["success"][0].Source)
+	assert.Equal(t, 1, events["filtered"][0].Result.NumberFilteredRows)
+}
+
+func TestHooksOnFailure(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Response        doristest.Response
+		ExpectAttempt   int
+		ExpectResult    bool
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "OnFailure should receive the error of the last attempt",
+			Response:        doristest.Response{Status: http.StatusServiceUnavailable},
+			ExpectAttempt:   2,
+		},
+		{
+			TestDescription: "OnFailure should receive a failed result, which is not retried",
+			Response:        doristest.Response{Body: `{"Label": "generated_label", "Status": "Fail", "Message": "too many filtered rows"}`},
+			ExpectAttempt:   1,
+			ExpectResult:    true,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		response := tc.Response
+		server := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+			return response
+		}))
+
+		var failures []loader.HookEvent
+		ld, err := loader.NewStreamLoader(
+			[]string{server.Host()},
+			"test_db",
+			"users",
+			loader.WithMaxRetry(2),
+			loader.WithRetryInterval(time.Millisecond),
+			loader.WithHooks(loader.Hooks{
+				OnFailure: func(ctx context.Context, event loader.HookEvent) {
+					failures = append(failures, event)
+				},
+			}),
+		)
+		assert.NoError(t, err)
+
+		_, _ = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+
+		if !assert.Len(t, failures, 1) {
+			continue
+		}
+		assert.Equal(t, tc.ExpectAttempt, failures[0].Attempt)
+		assert.Empty(t, failures[0].Source)
+		if tc.ExpectResult {
+			assert.NoError(t, failures[0].Err)
+			assert.Equal(t, "generated_label", failures[0].Label)
+		} else {
+			assert.Error(t, failures[0].Err)
+			assert.Nil(t, failures[0].Result)
+		}
+	}
+}
+
+func TestWithHooks(t *testing.T) {
+	t.Log("hooks without any callback or set twice should be rejected")
+
+	_, err := loader.NewStreamLoader([]string{"127.0.0.1:8030"}, "test_db", "users", loader.WithHooks(loader.Hooks{}))
+	assert.ErrorContains(t, err, loader.ErrZeroValueOption("Hooks").Error())
+
+	hooks := loader.Hooks{OnSuccess: func(ctx context.Context, event loader.HookEvent) {}}
+	_, err = loader.NewStreamLoader([]string{"127.0.0.1:8030"}, "test_db", "users", loader.WithHooks(hooks), loader.WithHooks(hooks))
+	assert.ErrorContains(t, err, loader.ErrAmbiguousOption("Hooks").Error())
+}
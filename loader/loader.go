@@ -3,8 +3,10 @@ package loader
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
@@ -14,20 +16,45 @@ import (
 	"github.com/raaaaaaaay86/doris-loader/enum"
 	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
 	"github.com/raaaaaaaay86/doris-loader/enum/protocol"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type StreamLoader struct {
-	Protocol      protocol.Enum   // stream protocol. (default: Http)
-	FeNodes       []string        // Frontend endpoints (e.g 127.0.0.1:8030)
-	BeNodes       []string        // Backend endpoints (e.g 127.0.0.1:8040)
-	Username      string          // Username
-	Password      string          // Password
-	Database      string          // Database name
-	Table         string          // Table name
-	Header        map[string]any  // Stream load header
-	LoadFormat    loadformat.Enum // Data format of loaded file (default: InlineJson)
-	MaxRetry      int             // Maximum retry count (default: 3)
-	RetryInterval time.Duration   // Retry interval (default: 1s)
+	Protocol            protocol.Enum        // stream protocol. (default: Http)
+	FeNodes             []string             // Frontend endpoints (e.g 127.0.0.1:8030)
+	BeNodes             []string             // Backend endpoints (e.g 127.0.0.1:8040)
+	Username            string               // Username
+	Password            string               // Password
+	Credentials         CredentialsProvider  // Credentials provider consulted on each request (default: Username and Password)
+	Database            string               // Database name
+	Table               string               // Table name
+	Header              map[string]any       // Stream load header
+	LoadFormat          loadformat.Enum      // Data format of loaded file (default: InlineJson)
+	MaxRetry            int                  // Maximum retry count (default: 3)
+	RetryInterval       time.Duration        // Retry interval (default: 1s)
+	BeSelector          BeSelector           // Strategy selecting the BE node of redirected requests (default: round-robin)
+	NodeCooldown        time.Duration        // Duration an unavailable node is skipped for (default: 30s)
+	HealthCheckInterval time.Duration        // Interval of the background FE health check, 0 disables it (default: 0)
+	BeDiscoveryInterval time.Duration        // Interval of discovering BE nodes from the FE, 0 disables it (default: 0)
+	ChunkSize           int64                // Approximate size in bytes of the chunks loaded by LoadFileParallel (default: 100MB)
+	Concurrency         int                  // Maximum number of concurrent stream loads of LoadFileParallel, LoadDir and LoadGlob (default: 4)
+	CheckpointFile      string               // Local file recording the outcome of each file loaded by LoadDir and LoadGlob
+	DeadLetterSink      DeadLetterSink       // Sink receiving the rows filtered by Doris
+	Metrics             MetricsRecorder      // Recorder receiving measurements of attempts and loads
+	Logger              *slog.Logger         // Logger receiving structured events of attempts, retries, node selection, redirects and results (default: nil, no logging)
+	TracerProvider      trace.TracerProvider // Provider of the tracer creating spans of loads, attempts and requests (default: nil, no tracing)
+	Transport           http.RoundTripper    // Transport of the requests sent to FE and BE nodes (default: http.DefaultTransport)
+	Middlewares         []Middleware         // Middlewares wrapping each stream load attempt, the first one being the outermost
+	Hooks               *Hooks               // Callbacks on attempts, retries and outcomes of loads
+	ValidateSchema      bool                 // Whether NewStreamLoader validates the options against the table schema (default: false)
+	SchemaTimeout       time.Duration        // Timeout of fetching the table schema to validate it (default: 10s)
+	RejectRow           RejectFunc           // Callback receiving the rows failing the client-side validation, nil disables the validation
+
+	fePool         *NodePool
+	bePool         *NodePool
+	stopBackground context.CancelFunc
+	schema         *schemaCache
+	appliedFields  map[string]bool // Fields set by the options, which override the client in Client.Table
 }
 
 // NewStreamLoader creates a new stream loader.
@@ -37,40 +64,96 @@ func NewStreamLoader(
 	table string,
 	options ...StreamLoaderOption,
 ) (*StreamLoader, error) {
-	loader := StreamLoader{
+	loader := newStreamLoader(feNodes, database, table)
+
+	if err := loader.checkRequiredFields(); err != nil {
+		return &loader, err
+	}
+
+	for _, option := range options {
+		if err := option(&loader); err != nil {
+			return &loader, err
+		}
+	}
+
+	if err := loader.applyDefaults(); err != nil {
+		return &loader, err
+	}
+
+	loader.fePool = NewNodePool(loader.FeNodes, loader.NodeCooldown)
+	loader.bePool = NewNodePool(loader.BeNodes, loader.NodeCooldown)
+	loader.schema = &schemaCache{}
+
+	if loader.ValidateSchema {
+		if err := loader.validateSchema(); err != nil {
+			return &loader, fmt.Errorf("%w: %w", ErrSchemaValidation, err)
+		}
+	}
+
+	loader.startBackground()
+
+	return &loader, nil
+}
+
+// newStreamLoader returns a loader with the default values, before the options are applied.
+func newStreamLoader(
+	feNodes []string,
+	database string,
+	table string,
+) StreamLoader {
+	return StreamLoader{
 		FeNodes:       feNodes,
 		Database:      database,
 		Table:         table,
 		MaxRetry:      3,
 		RetryInterval: 1 * time.Second,
+		NodeCooldown:  30 * time.Second,
+		SchemaTimeout: 10 * time.Second,
+		ChunkSize:     100 * 1024 * 1024,
+		Concurrency:   4,
 		Header: map[string]any{
 			"expect": "100-continue",
 		},
 	}
+}
 
-	if err := loader.checkRequiredFields(); err != nil {
-		return &loader, err
+// applyDefaults sets the default values of the fields left unset by the options.
+func (s *StreamLoader) applyDefaults() error {
+	if enum.IsZero(s.LoadFormat) {
+		if err := WithLoadFormat(loadformat.InlineJson)(s); err != nil {
+			return err
+		}
 	}
 
-	for _, option := range options {
-		if err := option(&loader); err != nil {
-			return &loader, err
+	if enum.IsZero(s.Protocol) {
+		if err := WithProtocol(protocol.Http)(s); err != nil {
+			return err
 		}
 	}
 
-	if enum.IsZero(loader.LoadFormat) {
-		if err := WithLoadFormat(loadformat.InlineJson)(&loader); err != nil {
-			return &loader, err
-		}
+	if s.BeSelector == nil {
+		s.BeSelector = NewRoundRobinSelector()
 	}
 
-	if enum.IsZero(loader.Protocol) {
-		if err := WithProtocol(protocol.Http)(&loader); err != nil {
-			return &loader, err
-		}
+	return nil
+}
+
+// startBackground starts the background health check and BE discovery if they're enabled. They're stopped by Close.
+func (s *StreamLoader) startBackground() {
+	if s.HealthCheckInterval <= 0 && s.BeDiscoveryInterval <= 0 {
+		return
 	}
 
-	return &loader, nil
+	ctx, cancel := context.WithCancel(context.Background())
+	s.stopBackground = cancel
+
+	if s.HealthCheckInterval > 0 {
+		s.startHealthCheck(ctx)
+	}
+
+	if s.BeDiscoveryInterval > 0 {
+		s.startBeDiscovery(ctx)
+	}
 }
 
 // LoadFile stream loads a file to Doris.
@@ -95,6 +178,8 @@ func NewStreamLoader(
 //	if result.IsSuccess() {
 //		// Do something for fail result...
 //	}
+//
+// If a DeadLetterSink is set and the load reports filtered rows, the rows are fetched from ErrorURL and written to the sink. The result is returned along with an error wrapped by ErrDeadLetter if that fails.
 func (s StreamLoader) LoadFile(
 	ctx context.Context,
 	filename string,
@@ -105,39 +190,82 @@ func (s StreamLoader) LoadFile(
 	}
 	defer file.Close()
 
-	var result *StreamLoadResult
-	feIndex := 0
-	tried := 0
+	return s.load(ctx, file, filename, "")
+}
 
-	for {
-		if tried != 0 {
-			time.Sleep(s.RetryInterval)
-		}
+// LoadReader stream loads the content of the reader to Doris. A non-empty label overrides the label of the loader. The reader is rewound before each retry.
+//
+// Like LoadFile, the result is returned along with an error wrapped by ErrDeadLetter if the filtered rows cannot be delivered to the DeadLetterSink.
+func (s StreamLoader) LoadReader(
+	ctx context.Context,
+	reader io.ReadSeeker,
+	label string,
+) (*StreamLoadResult, error) {
+	return s.load(ctx, reader, "", label)
+}
 
-		if feIndex >= len(s.FeNodes) {
-			feIndex = 0
-		}
+// load stream loads the payload through the RoundTrip of the loader, which retries it. The source names the payload in Hooks, and a non-empty label overrides the label header of the loader.
+func (s StreamLoader) load(
+	ctx context.Context,
+	payload io.ReadSeeker,
+	source string,
+	label string,
+) (*StreamLoadResult, error) {
+	if s.BeDiscoveryInterval > 0 && s.backends().Len() == 0 {
+		// Nothing discovered yet. The FE's own redirect is followed if the discovery fails again.
+		_ = s.discoverBackends(ctx)
+	}
 
-		feNode := s.FeNodes[feIndex]
-		feIndex++
-		tried++
+	payload, err := s.validateRows(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
 
-		req, err := s.buildRequest(feNode, file)
-		if err != nil {
-			return nil, err
-		}
+	size, err := payload.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
 
-		result, err = s.doRequest(req)
-		if err != nil {
-			if tried < s.MaxRetry {
-				continue
-			}
+	feNodes := s.frontends().Next()
+	ctx = withLoadState(ctx, &loadState{feNodes: feNodes, source: source, bytes: size})
 
-			return nil, err
-		}
+	req, err := s.buildRequest(ctx, feNodes[0], payload, label)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.roundTripper()(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if deliveryErr := s.deliverDeadLetters(ctx, result); deliveryErr != nil {
+		s.log(ctx, slog.LevelError, "dead letter delivery failed", slog.String("label", result.Label), slog.Any("error", deliveryErr))
+		return result, ErrDeadLetter(deliveryErr)
+	}
+
+	return result, nil
+}
+
+// credentials returns the credentials for the next request. It'll use Username and Password if no CredentialsProvider is set.
+func (s StreamLoader) credentials(ctx context.Context) (Credentials, error) {
+	if s.Credentials == nil {
+		return Credentials{
+			Username: s.Username,
+			Password: s.Password,
+		}, nil
+	}
+
+	return s.Credentials.Credentials(ctx)
+}
 
-		return result, nil
+// refreshCredentials asks the CredentialsProvider to reload the credentials.
+func (s StreamLoader) refreshCredentials(ctx context.Context) error {
+	if s.Credentials == nil {
+		return nil
 	}
+
+	return s.Credentials.Refresh(ctx)
 }
 
 // checkRequiredFields checks if required fields are set.
@@ -157,10 +285,12 @@ func (s StreamLoader) checkRequiredFields() error {
 	return nil
 }
 
-// buildRequest builds a http request for stream load.
+// buildRequest builds a http request for stream load. The credentials are set by the retry middleware for each attempt.
 func (s StreamLoader) buildRequest(
+	ctx context.Context,
 	feNode string,
-	payload io.Reader,
+	payload io.ReadSeeker,
+	label string,
 ) (*http.Request, error) {
 	url := fmt.Sprintf(
 		"%s://%s/api/%s/%s/_stream_load",
@@ -170,13 +300,16 @@ func (s StreamLoader) buildRequest(
 		s.Table,
 	)
 
-	req, err := http.NewRequest(http.MethodPut, url, io.NopCloser(payload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, io.NopCloser(payload))
 	if err != nil {
 		return nil, err
 	}
 
-	req.SetBasicAuth(s.Username, s.Password)
 	req.GetBody = func() (io.ReadCloser, error) {
+		if _, err := payload.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
 		return io.NopCloser(payload), nil
 	}
 
@@ -186,41 +319,110 @@ func (s StreamLoader) buildRequest(
 		}
 	}
 
+	if label != "" {
+		req.Header.Set("label", label)
+	}
+
 	return req, nil
 }
 
-// doRequest sends a stream load http request.
-func (s StreamLoader) doRequest(req *http.Request) (*StreamLoadResult, error) {
+// BeStatus returns the cached health state of the configured BE nodes.
+func (s StreamLoader) BeStatus() []NodeStatus {
+	return s.backends().Status()
+}
+
+// isDialError reports whether the request failed to connect to the node.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// failedHost returns the host of the request which failed, which is the host the FE redirected to if the redirect was followed, or fallback if the error doesn't tell.
+func failedHost(err error, fallback string) string {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return fallback
+	}
+
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil || u.Host == "" {
+		return fallback
+	}
+
+	return u.Host
+}
+
+// backends returns the BE node pool. A loader not built by NewStreamLoader gets a stateless pool of BeNodes.
+func (s StreamLoader) backends() *NodePool {
+	if s.bePool == nil {
+		return NewNodePool(s.BeNodes, s.NodeCooldown)
+	}
+
+	return s.bePool
+}
+
+// selectBeNode selects a healthy BE node for the request with the given label.
+func (s StreamLoader) selectBeNode(pool *NodePool, label string) (string, error) {
+	selector := s.BeSelector
+	if selector == nil {
+		selector = NewRoundRobinSelector()
+	}
+
+	candidates := pool.Available()
+	if len(candidates) == 0 {
+		return "", ErrNoAvailableBeNode
+	}
+
+	return selector.Select(label, candidates)
+}
+
+// doRequest sends a stream load http request. If BE nodes are configured, the redirect from the FE is sent to the BE node chosen by BeSelector instead.
+func (s StreamLoader) doRequest(req *http.Request, credentials Credentials) (*StreamLoadResult, string, error) {
+	pool := s.backends()
+	var beNode string
+
+	defer func() {
+		if beNode != "" {
+			pool.Release(beNode)
+		}
+	}()
+
 	client := &http.Client{
+		Transport: s.transport(),
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if len(s.BeNodes) == 0 {
+			if pool.Len() == 0 {
+				s.log(req.Context(), slog.LevelDebug, "following redirect",
+					slog.String("from", via[len(via)-1].URL.Host),
+					slog.String("to", redactURL(req.URL)),
+				)
 				return nil
 			}
 
-			var availableBeNode string
-			for _, node := range s.BeNodes {
-				conn, err := net.DialTimeout("tcp", node, 1*time.Second)
-				if err != nil {
-					continue
-				}
-				_ = conn.Close()
-				availableBeNode = node
-				break
+			selected, err := s.selectBeNode(pool, req.Header.Get("label"))
+			if err != nil {
+				s.log(req.Context(), slog.LevelWarn, "no backend node available", slog.Any("error", err))
+				return err
 			}
 
-			redirectTo, _ := url.Parse(
-				fmt.Sprintf(
-					"%s://%s:%s@%s/api/%s/%s/_stream_load",
-					s.Protocol,
-					s.Username,
-					s.Password,
-					availableBeNode,
-					s.Database,
-					s.Table,
-				),
+			s.log(req.Context(), slog.LevelDebug, "redirecting to selected backend node",
+				slog.String("from", via[len(via)-1].URL.Host),
+				slog.String("redirect", redactURL(req.URL)),
+				slog.String("be", selected),
+				slog.String("label", req.Header.Get("label")),
 			)
 
-			req.URL = redirectTo
+			if beNode != "" {
+				pool.Release(beNode)
+			}
+			beNode = selected
+			pool.Acquire(beNode)
+
+			req.URL = &url.URL{
+				Scheme: string(s.Protocol),
+				User:   url.UserPassword(credentials.Username, credentials.Password),
+				Host:   beNode,
+				Path:   fmt.Sprintf("/api/%s/%s/_stream_load", s.Database, s.Table),
+			}
 
 			return nil
 		},
@@ -228,20 +430,49 @@ func (s StreamLoader) doRequest(req *http.Request) (*StreamLoadResult, error) {
 
 	res, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		if req.Context().Err() == nil && !errors.Is(err, ErrNoAvailableBeNode) {
+			if beNode != "" {
+				// A dropped connection or a timeout may be transient, so only a BE node refusing connections is benched.
+				if isDialError(err) {
+					s.log(req.Context(), slog.LevelWarn, "backend node marked down", slog.String("be", beNode), slog.Any("error", err))
+					pool.MarkDown(beNode, err)
+				}
+			} else if failed := failedHost(err, req.URL.Host); s.frontends().has(failed) {
+				// The FE's own redirect may have been followed, in which case the BE node failed rather than the FE node.
+				s.log(req.Context(), slog.LevelWarn, "frontend node marked down", slog.String("fe", failed), slog.Any("error", err))
+				s.frontends().MarkDown(failed, err)
+			}
+		}
+
+		return nil, beNode, err
 	}
 	defer res.Body.Close()
 
+	// The FE's own redirect may have been followed instead of a selected BE node.
+	reached := beNode
+	if reached == "" && res.Request.URL.Host != req.URL.Host {
+		reached = res.Request.URL.Host
+	}
+
+	s.frontends().MarkUp(req.URL.Host)
+	if beNode != "" {
+		pool.MarkUp(beNode)
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, reached, ErrUnauthorized
+	}
+
 	data, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, err
+		return nil, reached, err
 	}
 
 	var result StreamLoadResult
 	err = json.Unmarshal(data, &result)
 	if err != nil {
-		return nil, err
+		return nil, reached, err
 	}
 
-	return &result, nil
+	return &result, reached, nil
 }
@@ -402,8 +402,18 @@ func TestNewStreamLoader(t *testing.T) {
 	}
 }
 
+// requireDorisCluster skips the test unless FE_NODES points it to a running Doris cluster, such as the one of docker-compose.
+func requireDorisCluster(t *testing.T) {
+	t.Helper()
+
+	if os.Getenv("FE_NODES") == "" {
+		t.Skip("FE_NODES is not set")
+	}
+}
+
 func TestStreamLoad(t *testing.T) {
 	t.Log("stream load a file to Doris")
+	requireDorisCluster(t)
 
 	feNodes := os.Getenv("FE_NODES")
 	beNodes := os.Getenv("BE_NODES")
@@ -438,6 +448,7 @@ func TestStreamLoad(t *testing.T) {
 
 func TestStreamLoadWithCsvLoadFormat(t *testing.T) {
 	t.Log("stream load a csv file to Doris")
+	requireDorisCluster(t)
 
 	feNodes := os.Getenv("FE_NODES")
 	beNodes := os.Getenv("BE_NODES")
@@ -477,6 +488,7 @@ func TestStreamLoadWithCsvLoadFormat(t *testing.T) {
 }
 func TestStreamLoadWithCsvWithNamesLoadFormat(t *testing.T) {
 	t.Log("stream load a csv file to Doris")
+	requireDorisCluster(t)
 
 	feNodes := os.Getenv("FE_NODES")
 	beNodes := os.Getenv("BE_NODES")
@@ -516,6 +528,7 @@ func TestStreamLoadWithCsvWithNamesLoadFormat(t *testing.T) {
 
 func TestStreamLoadCannotLoadBySameLabelTwice(t *testing.T) {
 	t.Log("stream load a csv file to Doris with same label twice")
+	requireDorisCluster(t)
 
 	feNodes := "127.0.0.1:8030"
 	beNodes := "127.0.0.1:8040"
@@ -568,6 +581,7 @@ func TestStreamLoadCannotLoadBySameLabelTwice(t *testing.T) {
 
 func TestStreamLoadWithCustomColumnSeparator(t *testing.T) {
 	t.Log("stream load a csv file to Doris with custom column separator")
+	requireDorisCluster(t)
 
 	feNodes := os.Getenv("FE_NODES")
 	beNodes := os.Getenv("BE_NODES")
@@ -607,6 +621,7 @@ func TestStreamLoadWithCustomColumnSeparator(t *testing.T) {
 
 func TestStreamLoadWithStrictMaxFilterRatio(t *testing.T) {
 	t.Log("stream load a file to Doris with max filter ratio")
+	requireDorisCluster(t)
 
 	feNodes := "127.0.0.1:8030"
 	beNodes := "127.0.0.1:8040"
@@ -639,6 +654,7 @@ func TestStreamLoadWithStrictMaxFilterRatio(t *testing.T) {
 
 func TestStreamLoadWithLooseMaxFilterRatio(t *testing.T) {
 	t.Log("stream load a file to Doris with max filter ratio")
+	requireDorisCluster(t)
 
 	feNodes := os.Getenv("FE_NODES")
 	beNodes := os.Getenv("BE_NODES")
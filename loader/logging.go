@@ -0,0 +1,164 @@
+package loader
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// log writes an event to the Logger, if any, along with the database and table of the loader. Credentials must never be passed as attributes, and URLs must go through redactURL.
+func (s StreamLoader) log(
+	ctx context.Context,
+	level slog.Level,
+	msg string,
+	attrs ...slog.Attr,
+) {
+	if s.Logger == nil || !s.Logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs = append([]slog.Attr{
+		slog.String("database", s.Database),
+		slog.String("table", s.Table),
+	}, attrs...)
+
+	s.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// resultAttrs returns the attributes describing a stream load result.
+func resultAttrs(result *StreamLoadResult) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("label", result.Label),
+		slog.String("status", result.Status),
+		slog.Int("txn_id", result.TxnId),
+		slog.Int("total_rows", result.NumberTotalRows),
+		slog.Int("loaded_rows", result.NumberLoadedRows),
+		slog.Int("filtered_rows", result.NumberFilteredRows),
+		slog.Int("load_bytes", result.LoadBytes),
+		slog.Int("load_time_ms", result.LoadTimeMs),
+	}
+
+	if result.Message != "" {
+		attrs = append(attrs, slog.String("message", result.Message))
+	}
+
+	if result.ErrorURL != "" {
+		attrs = append(attrs, slog.String("error_url", result.ErrorURL))
+	}
+
+	return attrs
+}
+
+// redactURL returns the URL without user information, since Doris puts the credentials in the URL of its redirects.
+func redactURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+
+	return redacted.String()
+}
+
+// loadLogging is the built-in middleware logging the outcome of a load.
+func (s StreamLoader) loadLogging(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		start := time.Now()
+		result, err := next(req)
+
+		ctx := req.Context()
+		attempt := loadStateFrom(ctx).attempt
+		duration := time.Since(start)
+
+		if err != nil {
+			s.log(ctx, slog.LevelError, "stream load failed",
+				slog.Int("attempt", attempt),
+				slog.String("label", req.Header.Get("label")),
+				slog.Duration("duration", duration),
+				slog.Any("error", err),
+			)
+			return nil, err
+		}
+
+		level := slog.LevelInfo
+		if !result.IsLoaded() {
+			level = slog.LevelWarn
+		}
+		s.log(ctx, level, "stream load finished", append(resultAttrs(result),
+			slog.Int("attempt", attempt),
+			slog.Duration("duration", duration),
+		)...)
+
+		return result, nil
+	}
+}
+
+// attemptLogging is the built-in middleware logging the start and the end of each attempt.
+func (s StreamLoader) attemptLogging(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		ctx := req.Context()
+		if s.Logger == nil || !s.Logger.Enabled(ctx, slog.LevelDebug) {
+			return next(req)
+		}
+
+		state := loadStateFrom(ctx)
+		s.log(ctx, slog.LevelDebug, "stream load attempt",
+			slog.String("fe", req.URL.Host),
+			slog.Int("attempt", state.attempt),
+			slog.String("label", req.Header.Get("label")),
+		)
+
+		var sent atomic.Int64
+		countSentBytes(req, &sent)
+
+		start := time.Now()
+		result, err := next(req)
+
+		attrs := []slog.Attr{
+			slog.String("fe", req.URL.Host),
+			slog.String("be", state.beNode),
+			slog.Int("attempt", state.attempt),
+			slog.String("label", req.Header.Get("label")),
+			slog.Int64("bytes_sent", sent.Load()),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+		s.log(ctx, slog.LevelDebug, "stream load attempt finished", attrs...)
+
+		return result, err
+	}
+}
+
+// countSentBytes adds the bytes of the body of the request read by the transport to sent, including the bodies of the redirects.
+func countSentBytes(req *http.Request, sent *atomic.Int64) {
+	if req.Body != nil {
+		req.Body = &countingReader{ReadCloser: req.Body, count: sent}
+	}
+
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+
+			return &countingReader{ReadCloser: body, count: sent}, nil
+		}
+	}
+}
+
+// countingReader counts the bytes read from a body, which the transport may read from another goroutine.
+type countingReader struct {
+	io.ReadCloser
+	count *atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.count.Add(int64(n))
+
+	return n, err
+}
@@ -0,0 +1,137 @@
+package loader_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	t.Log("attempts, retries, redirects and results should be logged without credentials")
+
+	be := doristest.NewServer(t)
+
+	attempts := 0
+	fe := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		attempts++
+		if attempts == 1 {
+			return doristest.Response{Status: http.StatusServiceUnavailable}
+		}
+
+		// Doris puts the credentials in the redirect URL.
+		return doristest.Response{Status: http.StatusTemporaryRedirect, Location: "http://root:s3cret@" + be.Host() + load.Path}
+	}))
+
+	var output bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe.Host()},
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithPassword("s3cret"),
+		loader.WithLabel("label_a"),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithLogger(logger),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+
+	assert.NotContains(t, output.String(), "s3cret")
+
+	var events []map[string]any
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		var event map[string]any
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, "test_db", event["database"])
+		events = append(events, event)
+	}
+
+	var messages []string
+	for _, event := range events {
+		messages = append(messages, event["msg"].(string))
+	}
+
+	assert.Equal(t, []string{
+		"stream load attempt",
+		"stream load attempt finished",
+		"stream load attempt failed, retrying",
+		"stream load attempt",
+		"following redirect",
+		"stream load attempt finished",
+		"stream load finished",
+	}, messages)
+
+	payload := float64(len(`{"name": "John Doe"}`))
+
+	assert.Equal(t, fe.Host(), events[1]["fe"])
+	assert.Equal(t, "", events[1]["be"])
+	assert.Equal(t, float64(1), events[1]["attempt"])
+	assert.Equal(t, payload, events[1]["bytes_sent"])
+	assert.Greater(t, events[1]["duration"], float64(0))
+	assert.NotEmpty(t, events[1]["error"])
+
+	assert.Equal(t, "http://"+be.Host()+"/api/test_db/users/_stream_load", events[4]["to"])
+
+	// The payload is sent to the FE and then to the BE it redirects to.
+	assert.Equal(t, fe.Host(), events[5]["fe"])
+	assert.Equal(t, be.Host(), events[5]["be"])
+	assert.Equal(t, float64(2), events[5]["attempt"])
+	assert.Equal(t, 2*payload, events[5]["bytes_sent"])
+	assert.Greater(t, events[5]["duration"], float64(0))
+	assert.NotContains(t, events[5], "error")
+
+	assert.Equal(t, "Success", events[6]["status"])
+	assert.Equal(t, float64(2), events[6]["attempt"])
+	assert.Greater(t, events[6]["duration"], events[5]["duration"])
+}
+
+func TestLoggerLogsFailedLoadDuration(t *testing.T) {
+	t.Log("a failed load should be logged with its duration")
+
+	var output bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&output, nil))
+
+	ld, err := loader.NewStreamLoader(
+		[]string{"127.0.0.1:1"},
+		"test_db",
+		"users",
+		loader.WithMaxRetry(2),
+		loader.WithRetryInterval(10*time.Millisecond),
+		loader.WithLogger(logger),
+	)
+	assert.NoError(t, err)
+
+	_, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.Error(t, err)
+
+	var failed map[string]any
+	scanner := bufio.NewScanner(&output)
+	for scanner.Scan() {
+		var event map[string]any
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		if event["msg"] == "stream load failed" {
+			failed = event
+		}
+	}
+
+	if assert.NotNil(t, failed) {
+		assert.Equal(t, float64(2), failed["attempt"])
+		assert.GreaterOrEqual(t, failed["duration"], float64(10*time.Millisecond))
+	}
+}
@@ -0,0 +1,97 @@
+package loader
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// MetricsRecorder receives measurements of the load path, such as the adapter of the prommetrics package. The methods are called synchronously, so they should not block.
+type MetricsRecorder interface {
+	// ObserveAttempt is called after every stream load request sent to an FE node.
+	ObserveAttempt(ctx context.Context, attempt AttemptMetrics)
+	// ObserveLoad is called once per load, after its last attempt.
+	ObserveLoad(ctx context.Context, load LoadMetrics)
+}
+
+// AttemptMetrics describes one stream load request.
+type AttemptMetrics struct {
+	Database string            // Database name
+	Table    string            // Table name
+	FeNode   string            // FE node the request was sent to
+	BeNode   string            // BE node the request was redirected to, empty if it didn't reach one
+	Attempt  int               // 1-based attempt number within the load
+	Duration time.Duration     // Duration of the request
+	Result   *StreamLoadResult // Stream load result, nil if the request failed
+	Err      error             // Request error, nil if the request was sent
+}
+
+// LoadMetrics describes a load, including its retries.
+type LoadMetrics struct {
+	Database string            // Database name
+	Table    string            // Table name
+	Attempts int               // Number of attempts, retries are Attempts - 1
+	Bytes    int64             // Size of the payload sent in bytes
+	Duration time.Duration     // Duration of the load including retry intervals
+	Result   *StreamLoadResult // Stream load result, nil if every attempt failed
+	Err      error             // Error of the last attempt, nil if a result was received
+}
+
+// observeAttempt passes the measurement of an attempt to the MetricsRecorder, if any.
+func (s StreamLoader) observeAttempt(ctx context.Context, attempt AttemptMetrics) {
+	if s.Metrics == nil {
+		return
+	}
+
+	attempt.Database, attempt.Table = s.Database, s.Table
+	s.Metrics.ObserveAttempt(ctx, attempt)
+}
+
+// observeLoad passes the measurement of a load to the MetricsRecorder, if any.
+func (s StreamLoader) observeLoad(ctx context.Context, load LoadMetrics) {
+	if s.Metrics == nil {
+		return
+	}
+
+	load.Database, load.Table = s.Database, s.Table
+	s.Metrics.ObserveLoad(ctx, load)
+}
+
+// loadMetrics is the built-in middleware passing the measurement of a load to the MetricsRecorder.
+func (s StreamLoader) loadMetrics(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		start := time.Now()
+		result, err := next(req)
+
+		state := loadStateFrom(req.Context())
+		s.observeLoad(req.Context(), LoadMetrics{
+			Attempts: state.attempt,
+			Bytes:    state.bytes,
+			Duration: time.Since(start),
+			Result:   result,
+			Err:      err,
+		})
+
+		return result, err
+	}
+}
+
+// attemptMetrics is the built-in middleware passing the measurement of each attempt to the MetricsRecorder.
+func (s StreamLoader) attemptMetrics(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		start := time.Now()
+		result, err := next(req)
+
+		state := loadStateFrom(req.Context())
+		s.observeAttempt(req.Context(), AttemptMetrics{
+			FeNode:   req.URL.Host,
+			BeNode:   state.beNode,
+			Attempt:  state.attempt,
+			Duration: time.Since(start),
+			Result:   result,
+			Err:      err,
+		})
+
+		return result, err
+	}
+}
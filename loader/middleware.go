@@ -0,0 +1,170 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// RoundTrip sends a stream load request and returns the parsed result.
+type RoundTrip func(req *http.Request) (*StreamLoadResult, error)
+
+// Middleware wraps a RoundTrip to inject behavior around stream load requests, such as custom headers, request signing, audit logging or fault injection. It may modify the request, inspect the result, or call next any number of times.
+//
+//	audit := func(next loader.RoundTrip) loader.RoundTrip {
+//		return func(req *http.Request) (*loader.StreamLoadResult, error) {
+//			result, err := next(req)
+//			log.Printf("%s attempt=%d err=%v", req.Header.Get("label"), loader.AttemptFromContext(req.Context()), err)
+//			return result, err
+//		}
+//	}
+type Middleware func(next RoundTrip) RoundTrip
+
+// loadState is shared by the middlewares of a load through the request context.
+type loadState struct {
+	feNodes []string // FE nodes in the order they are attempted
+	source  string   // Name of the loaded file, empty for a reader
+	bytes   int64    // Size of the payload in bytes
+	attempt int      // 1-based number of the current attempt, set by the retry middleware
+	beNode  string   // BE node reached by the current attempt, set by roundTrip
+}
+
+type loadStateKey struct{}
+
+// withLoadState returns a context carrying the state of a load.
+func withLoadState(ctx context.Context, state *loadState) context.Context {
+	return context.WithValue(ctx, loadStateKey{}, state)
+}
+
+// loadStateFrom returns the state of the load of the context. A context without one gets a detached state.
+func loadStateFrom(ctx context.Context) *loadState {
+	if state, ok := ctx.Value(loadStateKey{}).(*loadState); ok {
+		return state
+	}
+
+	return &loadState{}
+}
+
+// AttemptFromContext returns the 1-based attempt number of the request passed to a Middleware, or 0 if the context doesn't belong to a load.
+func AttemptFromContext(ctx context.Context) int {
+	return loadStateFrom(ctx).attempt
+}
+
+// chain wraps the RoundTrip with the middlewares, the first one being the outermost.
+func chain(roundTrip RoundTrip, middlewares ...Middleware) RoundTrip {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		roundTrip = middlewares[i](roundTrip)
+	}
+
+	return roundTrip
+}
+
+// roundTripper returns the RoundTrip of a load. The built-in middlewares observing the load wrap the retry middleware, which wraps the built-in middlewares observing each attempt and then the Middlewares of the loader.
+func (s StreamLoader) roundTripper() RoundTrip {
+	middlewares := []Middleware{
+		s.loadTracing,
+		s.loadMetrics,
+		s.loadLogging,
+		s.loadHooks,
+		s.retry,
+		s.attemptTracing,
+		s.attemptMetrics,
+		s.attemptLogging,
+		s.attemptHooks,
+	}
+
+	return chain(s.roundTrip, append(middlewares, s.Middlewares...)...)
+}
+
+// roundTrip is the innermost RoundTrip, sending the request to Doris.
+func (s StreamLoader) roundTrip(req *http.Request) (*StreamLoadResult, error) {
+	var credentials Credentials
+	if username, password, ok := req.BasicAuth(); ok {
+		credentials = Credentials{Username: username, Password: password}
+	}
+
+	result, beNode, err := s.doRequest(req, credentials)
+	loadStateFrom(req.Context()).beNode = beNode
+
+	return result, err
+}
+
+// retry is the built-in middleware sending the request to the FE nodes in turn until a result is received or MaxRetry attempts failed. An unauthorized attempt refreshes the credentials and is repeated once without consuming the retry budget.
+func (s StreamLoader) retry(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		ctx := req.Context()
+		state := loadStateFrom(ctx)
+		refreshed := false
+
+		for tried := 0; ; {
+			if tried != 0 {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				case <-time.After(s.RetryInterval):
+				}
+			}
+
+			feNode := state.feNodes[tried%len(state.feNodes)]
+			tried++
+			state.attempt = tried
+
+			result, err := s.attempt(next, req, feNode)
+			if errors.Is(err, ErrUnauthorized) && !refreshed {
+				// Credentials may have been rotated. Refresh them and retry once without consuming the retry budget.
+				refreshed = true
+				s.log(ctx, slog.LevelInfo, "refreshing credentials after unauthorized response", slog.String("fe", feNode))
+				if err := s.refreshCredentials(ctx); err != nil {
+					return nil, err
+				}
+
+				result, err = s.attempt(next, req, feNode)
+			}
+
+			if err == nil {
+				return result, nil
+			}
+
+			if tried >= s.MaxRetry {
+				return nil, err
+			}
+
+			s.hook(ctx, s.hooks().OnRetry, req, nil, err)
+			s.log(ctx, slog.LevelWarn, "stream load attempt failed, retrying",
+				slog.String("fe", feNode),
+				slog.Int("attempt", tried),
+				slog.Duration("retry_interval", s.RetryInterval),
+				slog.Any("error", err),
+			)
+		}
+	}
+}
+
+// attempt sends a copy of the request to the FE node with the current credentials. The payload is rewound so that it can be attempted repeatedly.
+func (s StreamLoader) attempt(
+	next RoundTrip,
+	req *http.Request,
+	feNode string,
+) (*StreamLoadResult, error) {
+	credentials, err := s.credentials(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+
+	attempt := req.Clone(req.Context())
+	attempt.URL.Host = feNode
+	attempt.Host = feNode
+	attempt.Body = body
+	attempt.SetBasicAuth(credentials.Username, credentials.Password)
+
+	loadStateFrom(req.Context()).beNode = ""
+
+	return next(attempt)
+}
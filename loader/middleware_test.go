@@ -0,0 +1,132 @@
+package loader_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	t.Log("middlewares should wrap each attempt in order, see the final request and the parsed result")
+
+	server := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		return doristest.Response{Body: `{"Label": "mw_label", "Status": "Success", "NumberLoadedRows": 1}`}
+	}))
+
+	var calls []string
+	audit := func(next loader.RoundTrip) loader.RoundTrip {
+		return func(req *http.Request) (*loader.StreamLoadResult, error) {
+			username, password, ok := req.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "root", username)
+			assert.Equal(t, "s3cret", password)
+			assert.Equal(t, "mw_label", req.Header.Get("label"))
+			assert.Equal(t, server.Host(), req.URL.Host)
+
+			calls = append(calls, "audit")
+			result, err := next(req)
+			if assert.NoError(t, err) {
+				assert.Equal(t, 1, result.NumberLoadedRows)
+			}
+
+			return result, err
+		}
+	}
+	sign := func(next loader.RoundTrip) loader.RoundTrip {
+		return func(req *http.Request) (*loader.StreamLoadResult, error) {
+			calls = append(calls, "sign")
+			req.Header.Set("X-Signature", "signed")
+			return next(req)
+		}
+	}
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithPassword("s3cret"),
+		loader.WithMiddleware(audit),
+		loader.WithMiddleware(sign),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "mw_label")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, []string{"audit", "sign"}, calls)
+	if assert.Len(t, server.Loads(), 1) {
+		assert.Equal(t, "signed", server.Loads()[0].Header.Get("X-Signature"))
+	}
+}
+
+func TestMiddlewareFaultInjection(t *testing.T) {
+	t.Log("an error injected by a middleware should be retried by the built-in retry middleware")
+
+	server := doristest.NewServer(t)
+
+	var attempts []int
+	injected := errors.New("injected fault")
+	fault := func(next loader.RoundTrip) loader.RoundTrip {
+		return func(req *http.Request) (*loader.StreamLoadResult, error) {
+			attempt := loader.AttemptFromContext(req.Context())
+			attempts = append(attempts, attempt)
+
+			if attempt < 3 {
+				// Consume part of the body to check it's rewound for the next attempt.
+				_, _ = io.ReadFull(req.Body, make([]byte, 4))
+				return nil, injected
+			}
+
+			return next(req)
+		}
+	}
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithMiddleware(fault),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, []int{1, 2, 3}, attempts)
+	if assert.Len(t, server.Loads(), 1) {
+		assert.Equal(t, `{"name": "John Doe"}`, string(server.Loads()[0].Payload))
+	}
+
+	t.Log("the injected error should be returned once the retries are exhausted")
+	attempts = nil
+	ld, err = loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithMaxRetry(2),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithMiddleware(fault),
+	)
+	assert.NoError(t, err)
+
+	_, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.ErrorIs(t, err, injected)
+	assert.Equal(t, []int{1, 2}, attempts)
+}
+
+func TestWithMiddleware(t *testing.T) {
+	t.Log("a nil middleware should be rejected")
+
+	_, err := loader.NewStreamLoader([]string{"127.0.0.1:8030"}, "test_db", "users", loader.WithMiddleware(nil))
+	assert.ErrorContains(t, err, loader.ErrZeroValueOption("Middlewares").Error())
+}
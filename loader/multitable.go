@@ -0,0 +1,195 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/enum"
+	"github.com/raaaaaaaay86/doris-loader/enum/queuepolicy"
+)
+
+// MultiTableLoader routes records to the tables of a database. It keeps a batch per table, created on the first record of the table, and flushes each batch independently by BatchSize and FlushInterval like AsyncLoader. The tables share the node pools, credentials, health state, hooks and defaults of the wrapped StreamLoader, whose Table is ignored, and up to its Concurrency loads are sent at once across all tables.
+type MultiTableLoader struct {
+	QueueSize      int               // Maximum number of queued records per table (default: 10000)
+	QueuePolicy    queuepolicy.Enum  // Behavior of Send when the queue of the table is full (default: Block)
+	BatchSize      int               // Maximum number of records in a batch (default: 10000)
+	FlushInterval  time.Duration     // Maximum time a record waits for its batch to fill (default: 1s)
+	ResultCallback func(AsyncResult) // Called with the result of each batch instead of delivering it on Results
+
+	loader  *StreamLoader
+	limiter chan struct{}
+	results chan AsyncResult
+
+	mu           sync.RWMutex
+	tables       map[string]*AsyncLoader
+	closed       bool
+	closeResults sync.Once
+}
+
+// NewMultiTableLoader creates a multi-table loader wrapping the stream loader. It takes the same options as NewAsyncLoader, which apply to the batch of every table. Call Close to flush the queued records of all tables.
+//
+// If no ResultCallback is set, the results must be received from Results, otherwise the loads stop once the results channel is full.
+func NewMultiTableLoader(
+	loader *StreamLoader,
+	options ...AsyncLoaderOption,
+) (*MultiTableLoader, error) {
+	if loader == nil {
+		return nil, ErrMissingRequiredValue("StreamLoader")
+	}
+
+	// The options are validated once on a template of the async loaders of the tables.
+	template := AsyncLoader{
+		QueueSize:     10000,
+		BatchSize:     10000,
+		FlushInterval: 1 * time.Second,
+	}
+
+	for _, option := range options {
+		if err := option(&template); err != nil {
+			return nil, err
+		}
+	}
+
+	if enum.IsZero(template.QueuePolicy) {
+		if err := WithQueuePolicy(queuepolicy.Block)(&template); err != nil {
+			return nil, err
+		}
+	}
+
+	concurrency := max(loader.Concurrency, 1)
+
+	return &MultiTableLoader{
+		QueueSize:      template.QueueSize,
+		QueuePolicy:    template.QueuePolicy,
+		BatchSize:      template.BatchSize,
+		FlushInterval:  template.FlushInterval,
+		ResultCallback: template.ResultCallback,
+		loader:         loader,
+		limiter:        make(chan struct{}, concurrency),
+		results:        make(chan AsyncResult, concurrency),
+		tables:         map[string]*AsyncLoader{},
+	}, nil
+}
+
+// Send queues a record to be loaded to the table. It'll block until there is room in the queue of the table if QueuePolicy is Block, or return ErrQueueFull if QueuePolicy is Reject.
+func (m *MultiTableLoader) Send(
+	ctx context.Context,
+	table string,
+	record []byte,
+) error {
+	async, err := m.table(table)
+	if err != nil {
+		return err
+	}
+
+	return async.Send(ctx, record)
+}
+
+// Tables returns the tables which received records, in alphabetical order.
+func (m *MultiTableLoader) Tables() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(m.tables))
+}
+
+// Results returns the channel delivering the result of each batch of every table. It's closed after the loader is closed and every batch is loaded.
+func (m *MultiTableLoader) Results() <-chan AsyncResult {
+	return m.results
+}
+
+// Close stops accepting records, loads the queued records of all tables and waits for them until the context is done. Once the context is done, the loads in flight are cancelled, and Results is closed after the remaining batches are delivered as failed.
+func (m *MultiTableLoader) Close(ctx context.Context) error {
+	m.mu.Lock()
+	m.closed = true
+	tables := slices.Collect(maps.Values(m.tables))
+	m.mu.Unlock()
+
+	var errs []error
+	for _, async := range tables {
+		errs = append(errs, async.Close(ctx))
+	}
+
+	// A table whose Close failed still delivers the cancelled batches, so the results are closed only once every table is drained.
+	for _, async := range tables {
+		<-async.done
+	}
+
+	m.closeResults.Do(func() {
+		close(m.results)
+	})
+
+	return errors.Join(errs...)
+}
+
+// table returns the async loader of the table, creating it on the first record of the table.
+func (m *MultiTableLoader) table(table string) (*AsyncLoader, error) {
+	if table == "" {
+		return nil, ErrMissingRequiredValue("Table")
+	}
+
+	m.mu.RLock()
+	async, ok := m.tables[table]
+	closed := m.closed
+	m.mu.RUnlock()
+
+	if closed {
+		return nil, ErrLoaderClosed
+	}
+
+	if ok {
+		return async, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrLoaderClosed
+	}
+
+	if async, ok := m.tables[table]; ok {
+		return async, nil
+	}
+
+	callback := m.ResultCallback
+	if callback == nil {
+		callback = func(result AsyncResult) {
+			m.results <- result
+		}
+	}
+
+	async = &AsyncLoader{
+		QueueSize:      m.QueueSize,
+		QueuePolicy:    m.QueuePolicy,
+		BatchSize:      m.BatchSize,
+		FlushInterval:  m.FlushInterval,
+		ResultCallback: callback,
+		loader:         m.loader.forTable(table),
+		limiter:        m.limiter,
+	}
+	async.start()
+
+	m.tables[table] = async
+
+	return async, nil
+}
+
+// forTable returns a copy of the loader bound to the table. The copy shares the node pools and settings of the loader, but gets its own schema cache, and its label is suffixed with the table since labels are unique within a database. The async loader of the table then appends its instance and batch sequence, so a restarted process doesn't reuse the labels of the previous one.
+func (s StreamLoader) forTable(table string) *StreamLoader {
+	s.Table = table
+	s.schema = &schemaCache{}
+	s.stopBackground = nil
+
+	if label, ok := s.Header["label"]; ok {
+		s.Header = maps.Clone(s.Header)
+		s.Header["label"] = fmt.Sprintf("%v_%s", label, table)
+	}
+
+	return &s
+}
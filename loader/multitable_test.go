@@ -0,0 +1,240 @@
+package loader_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiTableLoader(t *testing.T) {
+	t.Log("records should be batched per table and loaded to their table")
+
+	server := doristest.NewServer(t)
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"unused",
+		loader.WithLabel("events"),
+		loader.WithConcurrency(2),
+	)
+	assert.NoError(t, err)
+
+	multi, err := loader.NewMultiTableLoader(
+		ld,
+		loader.WithBatchSize(10),
+		loader.WithFlushInterval(time.Hour),
+	)
+	assert.NoError(t, err)
+
+	go func() {
+		for i := 0; i < 25; i++ {
+			assert.NoError(t, multi.Send(context.Background(), "users", []byte(fmt.Sprintf(`{"name": "user_%d"}`, i))))
+		}
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, multi.Send(context.Background(), "orders", []byte(fmt.Sprintf(`{"id": %d}`, i))))
+		}
+		assert.NoError(t, multi.Close(context.Background()))
+	}()
+
+	records := map[string]int{}
+	labels := []string{}
+	for result := range multi.Results() {
+		assert.NoError(t, result.Err)
+		assert.True(t, result.Result.IsSuccess())
+		records[result.Table] += result.Result.NumberLoadedRows
+		labels = append(labels, result.Label)
+	}
+
+	assert.Equal(t, map[string]int{"users": 25, "orders": 5}, records)
+	assert.Len(t, labels, 4)
+	for _, label := range labels {
+		assert.Regexp(t, `^events_(users_[0-9a-f]+_[0-2]|orders_[0-9a-f]+_0)$`, label)
+	}
+	assert.Equal(t, []string{"orders", "users"}, multi.Tables())
+
+	assert.ErrorIs(t, multi.Send(context.Background(), "users", []byte(`{}`)), loader.ErrLoaderClosed)
+	assert.ErrorIs(t, multi.Send(context.Background(), "payments", []byte(`{}`)), loader.ErrLoaderClosed)
+}
+
+func TestMultiTableLoaderRestart(t *testing.T) {
+	t.Log("a restarted multi-table loader should not reuse the batch labels of the previous one, which Doris would skip as already loaded")
+
+	orders := loader.TableSchema{
+		Database: "test_db",
+		Table:    "orders",
+		Columns:  []loader.Column{{Name: "id", Type: "INT", Nullable: true}},
+	}
+	server, err := dorisfake.NewServer(dorisfake.WithTable(asyncUsers), dorisfake.WithTable(orders))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "unused", loader.WithUsername("root"), loader.WithLabel("events"))
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	labels := map[string]bool{}
+	for run := 0; run < 2; run++ {
+		multi, err := loader.NewMultiTableLoader(ld, loader.WithResultCallback(func(result loader.AsyncResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.NoError(t, result.Err)
+			assert.True(t, result.Result.IsSuccess())
+			labels[result.Label] = true
+		}))
+		assert.NoError(t, err)
+
+		assert.NoError(t, multi.Send(context.Background(), "users", []byte(fmt.Sprintf(`{"name": "run_%d"}`, run))))
+		assert.NoError(t, multi.Send(context.Background(), "orders", []byte(fmt.Sprintf(`{"id": %d}`, run))))
+		assert.NoError(t, multi.Close(context.Background()))
+	}
+
+	assert.Len(t, labels, 4)
+	assert.Len(t, server.Rows("test_db", "users"), 2)
+	assert.Len(t, server.Rows("test_db", "orders"), 2)
+}
+
+func TestMultiTableLoaderSharesNodes(t *testing.T) {
+	t.Log("the tables should share the node health state and the concurrency of the stream loader")
+
+	options := []dorisfake.Option{}
+	plan := dorisfake.NewFaultPlan()
+	for i := 0; i < 8; i++ {
+		options = append(options, dorisfake.WithTable(loader.TableSchema{
+			Database: "test_db",
+			Table:    fmt.Sprintf("table_%d", i),
+			Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Nullable: true}},
+		}))
+		plan.OnBe(dorisfake.Delay(20 * time.Millisecond))
+	}
+
+	server, err := dorisfake.NewServer(append(options, dorisfake.WithFaultPlan(plan))...)
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	transport := &countingTransport{paths: map[string]int{}}
+	ld, err := loader.NewStreamLoader(
+		append([]string{"127.0.0.1:1"}, server.FeNodes()...),
+		"test_db",
+		"unused",
+		loader.WithUsername("root"),
+		loader.WithConcurrency(2),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithTransport(transport),
+	)
+	assert.NoError(t, err)
+
+	var mu sync.Mutex
+	var results []loader.AsyncResult
+	multi, err := loader.NewMultiTableLoader(
+		ld,
+		loader.WithBatchSize(1),
+		loader.WithResultCallback(func(result loader.AsyncResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		}),
+	)
+	assert.NoError(t, err)
+
+	for i := 0; i < 8; i++ {
+		assert.NoError(t, multi.Send(context.Background(), fmt.Sprintf("table_%d", i), []byte(`{"name": "John Doe"}`)))
+	}
+	assert.NoError(t, multi.Close(context.Background()))
+
+	assert.Len(t, results, 8)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+	}
+
+	tables := map[string]bool{}
+	for _, load := range server.Loads() {
+		tables[load.Table] = true
+	}
+	assert.Len(t, tables, 8)
+	assert.Zero(t, plan.Pending())
+	assert.LessOrEqual(t, transport.maxInflight, 2)
+
+	for _, status := range ld.FeStatus() {
+		assert.Equal(t, status.Addr != "127.0.0.1:1", status.Healthy, status.Addr)
+	}
+}
+
+func TestMultiTableLoaderCloseCancelsLoads(t *testing.T) {
+	t.Log("Close should return the error of a table whose context is done, and still close Results once every table is drained")
+
+	// Both loads are sent at once and take a minute.
+	plan := dorisfake.NewFaultPlan().OnFe(dorisfake.Delay(time.Minute), dorisfake.Delay(time.Minute))
+	options := []dorisfake.Option{dorisfake.WithFaultPlan(plan)}
+	for _, table := range []string{"users", "orders"} {
+		options = append(options, dorisfake.WithTable(loader.TableSchema{
+			Database: "test_db",
+			Table:    table,
+			Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true}},
+		}))
+	}
+
+	server, err := dorisfake.NewServer(options...)
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "unused", loader.WithUsername("root"), loader.WithConcurrency(2))
+	assert.NoError(t, err)
+
+	multi, err := loader.NewMultiTableLoader(ld, loader.WithBatchSize(1))
+	assert.NoError(t, err)
+
+	for _, table := range []string{"users", "orders"} {
+		assert.NoError(t, multi.Send(context.Background(), table, []byte(`{"name": "John Doe"}`)))
+	}
+
+	drained := make(chan []error)
+	go func() {
+		var errs []error
+		for result := range multi.Results() {
+			errs = append(errs, result.Err)
+		}
+		drained <- errs
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, multi.Close(ctx), context.DeadlineExceeded)
+
+	select {
+	case errs := <-drained:
+		if assert.Len(t, errs, 2) {
+			for _, err := range errs {
+				assert.ErrorIs(t, err, context.Canceled)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Results was not closed")
+	}
+}
+
+func TestNewMultiTableLoader(t *testing.T) {
+	t.Log("a missing stream loader, invalid options or an empty table should be rejected")
+
+	_, err := loader.NewMultiTableLoader(nil)
+	assert.ErrorContains(t, err, loader.ErrMissingRequiredValue("StreamLoader").Error())
+
+	ld, err := loader.NewStreamLoader([]string{"127.0.0.1:8030"}, "test_db", "unused")
+	assert.NoError(t, err)
+
+	_, err = loader.NewMultiTableLoader(ld, loader.WithBatchSize(10), loader.WithBatchSize(20))
+	assert.ErrorContains(t, err, loader.ErrAmbiguousOption("BatchSize").Error())
+
+	multi, err := loader.NewMultiTableLoader(ld)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, multi.Send(context.Background(), "", []byte(`{}`)), loader.ErrMissingRequiredValue("Table").Error())
+	assert.NoError(t, multi.Close(context.Background()))
+}
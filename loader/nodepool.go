@@ -0,0 +1,187 @@
+package loader
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NodeStatus is a snapshot of a node's health in a NodePool.
+type NodeStatus struct {
+	Addr      string    // Node endpoint (e.g 127.0.0.1:8040)
+	Healthy   bool      // Whether the node is considered available
+	Inflight  int       // Number of requests currently sent to the node
+	DownUntil time.Time // The node is skipped until this time after it was marked down
+	LastError string    // Last error observed on the node
+}
+
+// NodePool caches the health state of a set of nodes. A node marked down is skipped until its cooldown expires, after which it is tried again.
+type NodePool struct {
+	mu       sync.RWMutex
+	nodes    []*NodeStatus
+	cooldown time.Duration
+	next     atomic.Uint64
+}
+
+// NewNodePool creates a pool of nodes which are all considered healthy at the beginning.
+func NewNodePool(addrs []string, cooldown time.Duration) *NodePool {
+	pool := &NodePool{cooldown: cooldown}
+
+	for _, addr := range addrs {
+		pool.nodes = append(pool.nodes, &NodeStatus{Addr: addr, Healthy: true})
+	}
+
+	return pool
+}
+
+// Len returns the number of nodes in the pool.
+func (p *NodePool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.nodes)
+}
+
+// SetNodes replaces the nodes of the pool. The health state of the nodes already in the pool is kept.
+func (p *NodePool) SetNodes(addrs []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*NodeStatus, len(p.nodes))
+	for _, node := range p.nodes {
+		existing[node.Addr] = node
+	}
+
+	nodes := make([]*NodeStatus, 0, len(addrs))
+	for _, addr := range addrs {
+		if node, ok := existing[addr]; ok {
+			nodes = append(nodes, node)
+			continue
+		}
+
+		nodes = append(nodes, &NodeStatus{Addr: addr, Healthy: true})
+	}
+
+	p.nodes = nodes
+}
+
+// Status returns a snapshot of every node in the pool.
+func (p *NodePool) Status() []NodeStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]NodeStatus, 0, len(p.nodes))
+	for _, node := range p.nodes {
+		p.revive(node, now)
+		statuses = append(statuses, *node)
+	}
+
+	return statuses
+}
+
+// Available returns a snapshot of the healthy nodes in the pool.
+func (p *NodePool) Available() []NodeStatus {
+	statuses := p.Status()
+
+	available := make([]NodeStatus, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Healthy {
+			available = append(available, status)
+		}
+	}
+
+	return available
+}
+
+// Next returns the nodes in round-robin order, starting from the next node in turn. Healthy nodes come first, so unavailable nodes are only tried after every healthy node.
+func (p *NodePool) Next() []string {
+	statuses := p.Status()
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	start := int((p.next.Add(1) - 1) % uint64(len(statuses)))
+
+	var healthy, unavailable []string
+	for i := range statuses {
+		status := statuses[(start+i)%len(statuses)]
+		if status.Healthy {
+			healthy = append(healthy, status.Addr)
+		} else {
+			unavailable = append(unavailable, status.Addr)
+		}
+	}
+
+	return append(healthy, unavailable...)
+}
+
+// MarkDown marks the node as unavailable until the cooldown expires.
+func (p *NodePool) MarkDown(addr string, err error) {
+	p.update(addr, func(node *NodeStatus) {
+		node.Healthy = false
+		node.DownUntil = time.Now().Add(p.cooldown)
+		if err != nil {
+			node.LastError = err.Error()
+		}
+	})
+}
+
+// MarkUp marks the node as available.
+func (p *NodePool) MarkUp(addr string) {
+	p.update(addr, func(node *NodeStatus) {
+		node.Healthy = true
+		node.DownUntil = time.Time{}
+	})
+}
+
+// Acquire increases the inflight request count of the node.
+func (p *NodePool) Acquire(addr string) {
+	p.update(addr, func(node *NodeStatus) {
+		node.Inflight++
+	})
+}
+
+// Release decreases the inflight request count of the node.
+func (p *NodePool) Release(addr string) {
+	p.update(addr, func(node *NodeStatus) {
+		if node.Inflight > 0 {
+			node.Inflight--
+		}
+	})
+}
+
+// has reports whether the pool contains the node with the given address.
+func (p *NodePool) has(addr string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, node := range p.nodes {
+		if node.Addr == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// update applies fn to the node with the given address if it exists.
+func (p *NodePool) update(addr string, fn func(*NodeStatus)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, node := range p.nodes {
+		if node.Addr == addr {
+			fn(node)
+			return
+		}
+	}
+}
+
+// revive marks the node healthy again once its cooldown has expired. The caller must hold the write lock.
+func (p *NodePool) revive(node *NodeStatus, now time.Time) {
+	if !node.Healthy && !now.Before(node.DownUntil) {
+		node.Healthy = true
+		node.DownUntil = time.Time{}
+	}
+}
@@ -1,12 +1,15 @@
 package loader
 
 import (
+	"log/slog"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/raaaaaaaay86/doris-loader/enum"
 	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
 	"github.com/raaaaaaaay86/doris-loader/enum/protocol"
+	"go.opentelemetry.io/otel/trace"
 )
 
 
@@ -19,6 +22,7 @@ func WithLoadFormat(format loadformat.Enum) StreamLoaderOption {
 			return ErrAmbiguousOption("LoadFormat")
 		}
 
+		loader.markApplied("LoadFormat")
 		loader.LoadFormat = format
 
 		switch loader.LoadFormat {
@@ -50,6 +54,7 @@ func WithProtocol(p protocol.Enum) StreamLoaderOption {
 
 		switch p {
 		case protocol.Http, protocol.Https:
+			loader.markApplied("Protocol")
 			loader.Protocol = p
 		default:
 			if enum.IsZero(p) {
@@ -86,6 +91,11 @@ func WithUsername(username string) StreamLoaderOption {
 			return ErrAmbiguousOption("Username")
 		}
 
+		if loader.Credentials != nil {
+			return ErrAmbiguousOption("Credentials")
+		}
+
+		loader.markApplied("Username")
 		loader.Username = username
 
 		return nil
@@ -99,25 +109,117 @@ func WithPassword(password string) StreamLoaderOption {
 			return ErrAmbiguousOption("Password")
 		}
 
+		if loader.Credentials != nil {
+			return ErrAmbiguousOption("Credentials")
+		}
+
+		loader.markApplied("Password")
 		loader.Password = password
 
 		return nil
 	}
 }
 
+// WithCredentialsProvider sets the provider consulted for credentials on each request. It'll return an error if there has any provider, username or password set before.
+func WithCredentialsProvider(provider CredentialsProvider) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if provider == nil {
+			return ErrZeroValueOption("Credentials")
+		}
+
+		if loader.Credentials != nil || loader.Username != "" || loader.Password != "" {
+			return ErrAmbiguousOption("Credentials")
+		}
+
+		loader.markApplied("Credentials")
+		loader.Credentials = provider
+
+		return nil
+	}
+}
+
 // WithBeNodes sets the backend nodes for stream load. It'll return an error if there has any backend nodes set before.
 func WithBeNodes(beNodes []string) StreamLoaderOption {
 	return func(loader *StreamLoader) error {
-		if len(loader.BeNodes) != 0 {
+		if len(loader.BeNodes) != 0 || loader.BeDiscoveryInterval != 0 {
 			return ErrAmbiguousOption("BeNodes")
 		}
 
+		loader.markApplied("BeNodes")
 		loader.BeNodes = beNodes
 
 		return nil
 	}
 }
 
+// WithBeSelector sets the strategy selecting the backend node of redirected stream load requests. It'll return an error if there has any selector set before.
+func WithBeSelector(selector BeSelector) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if selector == nil {
+			return ErrZeroValueOption("BeSelector")
+		}
+
+		if loader.BeSelector != nil {
+			return ErrAmbiguousOption("BeSelector")
+		}
+
+		loader.markApplied("BeSelector")
+		loader.BeSelector = selector
+
+		return nil
+	}
+}
+
+// WithNodeCooldown sets how long a node is skipped after it failed. It'll return an error if there has any cooldown set before.
+func WithNodeCooldown(cooldown time.Duration) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if loader.NodeCooldown != 30*time.Second && loader.NodeCooldown != cooldown { // 30 seconds is the default value
+			return ErrAmbiguousOption("NodeCooldown")
+		}
+
+		loader.markApplied("NodeCooldown")
+		loader.NodeCooldown = cooldown
+
+		return nil
+	}
+}
+
+// WithHealthCheck enables the background health check of FE nodes by the given interval. Unhealthy FE nodes are tried only after every healthy one. Call StreamLoader.Close to stop it. It'll return an error if there has any interval set before.
+func WithHealthCheck(interval time.Duration) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if interval <= 0 {
+			return ErrUnsupportValue("HealthCheckInterval")
+		}
+
+		if loader.HealthCheckInterval != 0 && loader.HealthCheckInterval != interval {
+			return ErrAmbiguousOption("HealthCheckInterval")
+		}
+
+		loader.markApplied("HealthCheckInterval")
+		loader.HealthCheckInterval = interval
+
+		return nil
+	}
+}
+
+// WithBeDiscovery discovers the alive backend nodes from the FE by the given interval instead of using a static list of WithBeNodes. Call StreamLoader.Close to stop it. It'll return an error if there has any interval or backend nodes set before.
+func WithBeDiscovery(interval time.Duration) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if interval <= 0 {
+			return ErrUnsupportValue("BeDiscoveryInterval")
+		}
+
+		if len(loader.BeNodes) != 0 || (loader.BeDiscoveryInterval != 0 && loader.BeDiscoveryInterval != interval) {
+			return ErrAmbiguousOption("BeNodes")
+		}
+
+		loader.markApplied("BeDiscoveryInterval")
+		loader.BeDiscoveryInterval = interval
+
+		return nil
+	}
+}
+
 // WithColumns sets the columns name of CSV file. It'll return an error if there has any columns set before.
 func WithColumns(columns []string) StreamLoaderOption {
 	return func(loader *StreamLoader) error {
@@ -138,6 +240,7 @@ func WithMaxRetry(retry int) StreamLoaderOption {
 			return ErrAmbiguousOption("MaxRetry")
 		}
 
+		loader.markApplied("MaxRetry")
 		loader.MaxRetry = retry
 
 		return nil
@@ -151,12 +254,167 @@ func WithRetryInterval(interval time.Duration) StreamLoaderOption {
 			return ErrAmbiguousOption("RetryInterval")
 		}
 
+		loader.markApplied("RetryInterval")
 		loader.RetryInterval = interval
 
 		return nil
 	}
 }
 
+// WithChunkSize sets the approximate size in bytes of the chunks loaded by LoadFileParallel. It'll return an error if there has any chunk size set before.
+func WithChunkSize(size int64) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if size <= 0 {
+			return ErrUnsupportValue("ChunkSize")
+		}
+
+		if loader.ChunkSize != 100*1024*1024 && loader.ChunkSize != size { // 100MB is the default value
+			return ErrAmbiguousOption("ChunkSize")
+		}
+
+		loader.markApplied("ChunkSize")
+		loader.ChunkSize = size
+
+		return nil
+	}
+}
+
+// WithConcurrency sets the maximum number of concurrent stream loads. It'll return an error if there has any concurrency set before.
+func WithConcurrency(concurrency int) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if concurrency <= 0 {
+			return ErrUnsupportValue("Concurrency")
+		}
+
+		if loader.Concurrency != 4 && loader.Concurrency != concurrency { // 4 is the default value
+			return ErrAmbiguousOption("Concurrency")
+		}
+
+		loader.markApplied("Concurrency")
+		loader.Concurrency = concurrency
+
+		return nil
+	}
+}
+
+// WithCheckpointFile sets the local file recording the label and outcome of each file loaded by LoadDir and LoadGlob. It'll return an error if there has any checkpoint file set before.
+func WithCheckpointFile(path string) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if path == "" {
+			return ErrZeroValueOption("CheckpointFile")
+		}
+
+		if loader.CheckpointFile != "" && loader.CheckpointFile != path {
+			return ErrAmbiguousOption("CheckpointFile")
+		}
+
+		loader.markApplied("CheckpointFile")
+		loader.CheckpointFile = path
+
+		return nil
+	}
+}
+
+// WithDeadLetterSink sets the sink receiving the rows filtered by Doris, fetched from ErrorURL whenever a load reports filtered rows. It'll return an error if there has any sink set before.
+func WithDeadLetterSink(sink DeadLetterSink) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if sink == nil {
+			return ErrZeroValueOption("DeadLetterSink")
+		}
+
+		if loader.DeadLetterSink != nil {
+			return ErrAmbiguousOption("DeadLetterSink")
+		}
+
+		loader.markApplied("DeadLetterSink")
+		loader.DeadLetterSink = sink
+
+		return nil
+	}
+}
+
+// WithSchemaValidation makes NewStreamLoader fetch the table schema from the FE and validate the columns, load format and partial update settings against it. It'll return an error from NewStreamLoader if the schema cannot be fetched or doesn't match.
+func WithSchemaValidation() StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		loader.markApplied("ValidateSchema")
+		loader.ValidateSchema = true
+
+		return nil
+	}
+}
+
+// WithSchemaTimeout sets the timeout of fetching the table schema for WithSchemaValidation. It'll return an error if there has any timeout set before.
+func WithSchemaTimeout(timeout time.Duration) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if timeout <= 0 {
+			return ErrUnsupportValue("SchemaTimeout")
+		}
+
+		if loader.SchemaTimeout != 10*time.Second && loader.SchemaTimeout != timeout { // 10 seconds is the default value
+			return ErrAmbiguousOption("SchemaTimeout")
+		}
+
+		loader.markApplied("SchemaTimeout")
+		loader.SchemaTimeout = timeout
+
+		return nil
+	}
+}
+
+// WithRowValidation validates the rows against the table schema before sending them. Invalid rows are passed to reject and only the valid ones are loaded, which makes WithMaxFilterRatio(0) practical. The payload is buffered in memory to be filtered. It'll return an error if there has any reject callback set before.
+func WithRowValidation(reject RejectFunc) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if reject == nil {
+			return ErrZeroValueOption("RejectRow")
+		}
+
+		if loader.RejectRow != nil {
+			return ErrAmbiguousOption("RejectRow")
+		}
+
+		loader.markApplied("RejectRow")
+		loader.RejectRow = reject
+
+		return nil
+	}
+}
+
+// WithLogger sets the logger receiving structured events of attempts, retries, node selection, redirects and results. Credentials are never logged. It'll return an error if there has any logger set before.
+func WithLogger(logger *slog.Logger) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if logger == nil {
+			return ErrZeroValueOption("Logger")
+		}
+
+		if loader.Logger != nil && loader.Logger != logger {
+			return ErrAmbiguousOption("Logger")
+		}
+
+		loader.markApplied("Logger")
+		loader.Logger = logger
+
+		return nil
+	}
+}
+
+// WithMetricsRecorder sets the recorder receiving measurements of attempts and loads. It'll return an error if there has any recorder set before.
+func WithMetricsRecorder(recorder MetricsRecorder) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if recorder == nil {
+			return ErrZeroValueOption("Metrics")
+		}
+
+		if loader.Metrics != nil {
+			return ErrAmbiguousOption("Metrics")
+		}
+
+		loader.markApplied("Metrics")
+		loader.Metrics = recorder
+
+		return nil
+	}
+}
+
 // WithLabel sets the label for stream load in order to prevent duplicate data loading. It'll return an error if there has any label set before.
 func WithLabel(label string) StreamLoaderOption {
 	return func(loader *StreamLoader) error {
@@ -198,4 +456,73 @@ func WithMaxFilterRatio(ratio float64) StreamLoaderOption {
 
 		return nil
 	}
-}
\ No newline at end of file
+}
+
+// WithTracerProvider enables tracing with the provider. Each load creates a span with child spans for its attempts, FE requests and BE redirects, and the W3C trace context is propagated in the request headers. It'll return an error if there has any provider set before.
+func WithTracerProvider(provider trace.TracerProvider) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if provider == nil {
+			return ErrZeroValueOption("TracerProvider")
+		}
+
+		if loader.TracerProvider != nil {
+			return ErrAmbiguousOption("TracerProvider")
+		}
+
+		loader.markApplied("TracerProvider")
+		loader.TracerProvider = provider
+
+		return nil
+	}
+}
+
+// WithMiddleware appends middlewares wrapping each stream load attempt, the first one being the outermost. They run inside the built-in retry middleware, so they see the final request of every attempt.
+func WithMiddleware(middlewares ...Middleware) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		for _, middleware := range middlewares {
+			if middleware == nil {
+				return ErrZeroValueOption("Middlewares")
+			}
+		}
+
+		loader.Middlewares = append(loader.Middlewares, middlewares...)
+
+		return nil
+	}
+}
+
+// WithHooks sets the callbacks on attempts, retries and outcomes of loads. It'll return an error if there has any hooks set before or none of the callbacks is set.
+func WithHooks(hooks Hooks) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if hooks.OnAttempt == nil && hooks.OnRetry == nil && hooks.OnSuccess == nil && hooks.OnFailure == nil && hooks.OnFilteredRows == nil {
+			return ErrZeroValueOption("Hooks")
+		}
+
+		if loader.Hooks != nil {
+			return ErrAmbiguousOption("Hooks")
+		}
+
+		loader.markApplied("Hooks")
+		loader.Hooks = &hooks
+
+		return nil
+	}
+}
+
+// WithTransport sets the transport of the requests sent to FE and BE nodes, e.g. to record them or to route them through a proxy. It'll return an error if there has any transport set before.
+func WithTransport(transport http.RoundTripper) StreamLoaderOption {
+	return func(loader *StreamLoader) error {
+		if transport == nil {
+			return ErrZeroValueOption("Transport")
+		}
+
+		if loader.Transport != nil {
+			return ErrAmbiguousOption("Transport")
+		}
+
+		loader.markApplied("Transport")
+		loader.Transport = transport
+
+		return nil
+	}
+}
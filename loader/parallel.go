@@ -0,0 +1,316 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+)
+
+// ChunkResult is the result of stream loading one chunk of a file.
+type ChunkResult struct {
+	Index  int               // Index of the chunk in the file
+	Offset int64             // Offset of the chunk in the file
+	Length int64             // Length of the chunk in bytes
+	Label  string            // Stream load label of the chunk
+	Result *StreamLoadResult // Stream load result, nil if the request failed
+	Err    error             // Request error, nil if the request was sent
+}
+
+// IsSuccess reports whether the chunk was loaded, including by a previous attempt with the same label.
+func (c ChunkResult) IsSuccess() bool {
+	return c.Err == nil && c.Result != nil && c.Result.IsLoaded()
+}
+
+// ParallelLoadResult is the result of LoadFileParallel.
+type ParallelLoadResult struct {
+	Filename  string           // Loaded file
+	Aggregate StreamLoadResult // Sum of the row counts and bytes of the chunks, with the longest timings
+	Chunks    []ChunkResult    // Result of every chunk
+}
+
+// IsSuccess reports whether every chunk was loaded.
+func (r ParallelLoadResult) IsSuccess() bool {
+	for _, chunk := range r.Chunks {
+		if !chunk.IsSuccess() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Failed returns the chunks which were not loaded.
+func (r ParallelLoadResult) Failed() []ChunkResult {
+	var failed []ChunkResult
+	for _, chunk := range r.Chunks {
+		if !chunk.IsSuccess() {
+			failed = append(failed, chunk)
+		}
+	}
+
+	return failed
+}
+
+// LoadFileParallel splits a line-based file (InlineJson, Csv or CsvWithNames) on line boundaries into chunks of about ChunkSize bytes and stream loads them concurrently by Concurrency workers. Each chunk gets a deterministic label made of the loader label, or a digest of the file, and the chunk index. The header line of CsvWithNames is repeated in every chunk. Quoted fields containing line breaks are not supported.
+//
+// Errors of single chunks are reported in ParallelLoadResult instead of the returned error. Pass the result to ResumeFileParallel to load only the failed chunks again.
+func (s StreamLoader) LoadFileParallel(
+	ctx context.Context,
+	filename string,
+) (*ParallelLoadResult, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := s.chunkHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	offsets, err := splitLines(file, int64(len(header)), info.Size(), s.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	baseLabel := s.fileLabel(filename, info)
+
+	result := &ParallelLoadResult{Filename: filename}
+	for i := 0; i < len(offsets)-1; i++ {
+		result.Chunks = append(result.Chunks, ChunkResult{
+			Index:  i,
+			Offset: offsets[i],
+			Length: offsets[i+1] - offsets[i],
+			Label:  fmt.Sprintf("%s_%d", baseLabel, i),
+		})
+	}
+
+	s.loadChunks(ctx, file, header, baseLabel, result)
+
+	return result, nil
+}
+
+// ResumeFileParallel loads the failed chunks of a previous LoadFileParallel result again with their original labels. The file must not have been modified since.
+func (s StreamLoader) ResumeFileParallel(
+	ctx context.Context,
+	previous *ParallelLoadResult,
+) (*ParallelLoadResult, error) {
+	file, err := os.Open(previous.Filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := s.chunkHeader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ParallelLoadResult{
+		Filename: previous.Filename,
+		Chunks:   append([]ChunkResult(nil), previous.Chunks...),
+	}
+
+	s.loadChunks(ctx, file, header, previous.Aggregate.Label, result)
+
+	return result, nil
+}
+
+// loadChunks loads the chunks of the result which are not loaded yet and aggregates the results.
+func (s StreamLoader) loadChunks(
+	ctx context.Context,
+	file *os.File,
+	header []byte,
+	baseLabel string,
+	result *ParallelLoadResult,
+) {
+	concurrency := max(s.Concurrency, 1)
+	pending := make(chan int)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range pending {
+				chunk := &result.Chunks[index]
+				payload := newChunkPayload(header, io.NewSectionReader(file, chunk.Offset, chunk.Length))
+				chunk.Result, chunk.Err = s.load(ctx, payload, fmt.Sprintf("%s#%d", file.Name(), chunk.Index), chunk.Label)
+			}
+		}()
+	}
+
+	for index, chunk := range result.Chunks {
+		if !chunk.IsSuccess() {
+			pending <- index
+		}
+	}
+	close(pending)
+	wg.Wait()
+
+	result.Aggregate = aggregateChunks(baseLabel, result.Chunks)
+}
+
+// chunkHeader returns the header line repeated in every chunk. Only CsvWithNames has a header line.
+func (s StreamLoader) chunkHeader(file *os.File) ([]byte, error) {
+	if s.LoadFormat != loadformat.CsvWithNames {
+		return nil, nil
+	}
+
+	header, err := bufio.NewReader(io.NewSectionReader(file, 0, 1<<62)).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// fileLabel returns the label the chunks of the file are derived from. The loader label is used if there is one, otherwise it's a digest of the file.
+func (s StreamLoader) fileLabel(filename string, info os.FileInfo) string {
+	if label, ok := s.Header["label"]; ok {
+		return fmt.Sprintf("%v", label)
+	}
+
+	return "doris_loader_" + fileDigest(filename, info)
+}
+
+// fileDigest returns a digest of the file path, size and modification time, which changes whenever the file is replaced or modified.
+func fileDigest(filename string, info os.FileInfo) string {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		path = filename
+	}
+
+	digest := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%d", path, info.Size(), info.ModTime().UnixNano())))
+
+	return hex.EncodeToString(digest[:8])
+}
+
+// splitLines returns the offsets splitting [start, size) of the file into chunks of at least chunkSize bytes ending on a line break. The returned offsets include both start and size.
+func splitLines(file io.ReaderAt, start int64, size int64, chunkSize int64) ([]int64, error) {
+	offsets := []int64{start}
+	if chunkSize <= 0 {
+		chunkSize = size
+	}
+
+	for offset := start; offset < size; {
+		next := offset + chunkSize
+		if next >= size {
+			offsets = append(offsets, size)
+			break
+		}
+
+		// Move the boundary forward to right after the next line break.
+		reader := bufio.NewReader(io.NewSectionReader(file, next-1, size-next+1))
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		next += int64(len(line)) - 1
+		if next >= size {
+			offsets = append(offsets, size)
+			break
+		}
+
+		offsets = append(offsets, next)
+		offset = next
+	}
+
+	return offsets, nil
+}
+
+// aggregateChunks sums the row counts and bytes of the chunks. The timings are the longest among the chunks since they are loaded concurrently.
+func aggregateChunks(label string, chunks []ChunkResult) StreamLoadResult {
+	aggregate := StreamLoadResult{
+		Label:  label,
+		Status: "Success",
+	}
+
+	for _, chunk := range chunks {
+		if !chunk.IsSuccess() && aggregate.Status == "Success" {
+			aggregate.Status = "Fail"
+			aggregate.Message = fmt.Sprintf("chunk %d failed", chunk.Index)
+
+			if chunk.Err != nil {
+				aggregate.Message += ": " + chunk.Err.Error()
+			} else if chunk.Result != nil {
+				aggregate.Message += ": " + chunk.Result.Message
+				aggregate.ErrorURL = chunk.Result.ErrorURL
+			}
+		}
+
+		if chunk.Result == nil {
+			continue
+		}
+
+		result := chunk.Result
+		aggregate.NumberTotalRows += result.NumberTotalRows
+		aggregate.NumberLoadedRows += result.NumberLoadedRows
+		aggregate.NumberFilteredRows += result.NumberFilteredRows
+		aggregate.NumberUnselectedRows += result.NumberUnselectedRows
+		aggregate.LoadBytes += result.LoadBytes
+		aggregate.LoadTimeMs = max(aggregate.LoadTimeMs, result.LoadTimeMs)
+		aggregate.BeginTxnTimeMs = max(aggregate.BeginTxnTimeMs, result.BeginTxnTimeMs)
+		aggregate.StreamLoadPutTimeMs = max(aggregate.StreamLoadPutTimeMs, result.StreamLoadPutTimeMs)
+		aggregate.ReadDataTimeMs = max(aggregate.ReadDataTimeMs, result.ReadDataTimeMs)
+		aggregate.WriteDataTimeMs = max(aggregate.WriteDataTimeMs, result.WriteDataTimeMs)
+		aggregate.CommitAndPublishTimeMs = max(aggregate.CommitAndPublishTimeMs, result.CommitAndPublishTimeMs)
+	}
+
+	return aggregate
+}
+
+// chunkPayload is a rewindable payload of a header followed by a section of the file.
+type chunkPayload struct {
+	header  []byte
+	section *io.SectionReader
+	reader  io.Reader
+}
+
+func newChunkPayload(header []byte, section *io.SectionReader) *chunkPayload {
+	payload := &chunkPayload{header: header, section: section}
+	_, _ = payload.Seek(0, io.SeekStart)
+
+	return payload
+}
+
+func (c *chunkPayload) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// Seek only supports rewinding to the start, which is all the retries need, and seeking to the end to measure the size.
+func (c *chunkPayload) Seek(offset int64, whence int) (int64, error) {
+	if offset == 0 && whence == io.SeekEnd {
+		c.reader = bytes.NewReader(nil)
+
+		return int64(len(c.header)) + c.section.Size(), nil
+	}
+
+	if offset != 0 || whence != io.SeekStart {
+		return 0, ErrUnsupportValue("seek")
+	}
+
+	if _, err := c.section.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	c.reader = io.MultiReader(bytes.NewReader(c.header), c.section)
+
+	return 0, nil
+}
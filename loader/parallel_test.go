@@ -0,0 +1,135 @@
+package loader_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+// newChunkFe accepts stream loads, failing the first load of each label in failing.
+func newChunkFe(t *testing.T, failing ...string) *doristest.Server {
+	t.Helper()
+
+	var mu sync.Mutex
+	remaining := map[string]bool{}
+	for _, label := range failing {
+		remaining[label] = true
+	}
+
+	return doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if label := load.Header.Get("label"); remaining[label] {
+			delete(remaining, label)
+			return doristest.Response{Body: `{"Status": "Fail", "Message": "injected failure"}`}
+		}
+
+		return doristest.Success(load)
+	}))
+}
+
+// loadedBodies returns the payload of the last load of each label.
+func loadedBodies(server *doristest.Server) map[string]string {
+	bodies := map[string]string{}
+	for _, load := range server.Loads() {
+		bodies[load.Header.Get("label")] = string(load.Payload)
+	}
+
+	return bodies
+}
+
+func writeLines(t *testing.T, header string, count int) string {
+	t.Helper()
+
+	var builder strings.Builder
+	builder.WriteString(header)
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&builder, "{\"name\": \"user_%02d\", \"age\": %d}\n", i, i)
+	}
+
+	filename := filepath.Join(t.TempDir(), "users.json")
+	assert.NoError(t, os.WriteFile(filename, []byte(builder.String()), 0o600))
+
+	return filename
+}
+
+func TestLoadFileParallel(t *testing.T) {
+	t.Log("a file should be split on line boundaries and every chunk should be loaded with a deterministic label")
+
+	server := newChunkFe(t, "users_1")
+	filename := writeLines(t, "", 10)
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithLabel("users"),
+		loader.WithChunkSize(70),
+		loader.WithConcurrency(2),
+		loader.WithMaxRetry(1),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFileParallel(context.Background(), filename)
+	assert.NoError(t, err)
+	assert.False(t, result.IsSuccess())
+	assert.Len(t, result.Failed(), 1)
+	assert.Equal(t, "users_1", result.Failed()[0].Label)
+	assert.Equal(t, "Fail", result.Aggregate.Status)
+
+	result, err = ld.ResumeFileParallel(context.Background(), result)
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, "Success", result.Aggregate.Status)
+	assert.Equal(t, 10, result.Aggregate.NumberLoadedRows)
+
+	content, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+
+	bodies := loadedBodies(server)
+	var loaded strings.Builder
+	for _, chunk := range result.Chunks {
+		body := bodies[chunk.Label]
+		assert.True(t, strings.HasSuffix(body, "\n"))
+		loaded.WriteString(body)
+	}
+	assert.Equal(t, string(content), loaded.String())
+}
+
+func TestLoadFileParallelRepeatsCsvHeader(t *testing.T) {
+	t.Log("every chunk of a csv_with_names file should start with the header line")
+
+	server := newChunkFe(t)
+	filename := filepath.Join(t.TempDir(), "users.csv")
+	assert.NoError(t, os.WriteFile(filename, []byte("name,age\nJohn,30\nJane,31\nKimi,20\n"), 0o600))
+
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithLoadFormat(loadformat.CsvWithNames),
+		loader.WithChunkSize(8),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadFileParallel(context.Background(), filename)
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Len(t, result.Chunks, 3)
+
+	bodies := loadedBodies(server)
+	for _, chunk := range result.Chunks {
+		assert.True(t, strings.HasPrefix(bodies[chunk.Label], "name,age\n"))
+	}
+	assert.Equal(t, "name,age\nJane,31\n", bodies[result.Chunks[1].Label])
+}
@@ -21,12 +21,23 @@ type StreamLoadResult struct {
 	WriteDataTimeMs        int    `json:"WriteDataTimeMs"`
 	CommitAndPublishTimeMs int    `json:"CommitAndPublishTimeMs"`
 	ErrorURL               string `json:"ErrorURL"`
+	ExistingJobStatus      string `json:"ExistingJobStatus"`
 }
 
 func (s StreamLoadResult) IsSuccess() bool {
 	return s.Status == "Success"
 }
 
+// IsAlreadyLoaded reports whether the label was loaded successfully by a previous request, which happens when a load is retried after its response was lost.
+func (s StreamLoadResult) IsAlreadyLoaded() bool {
+	return s.Status == "Label Already Exists" && s.ExistingJobStatus == "FINISHED"
+}
+
+// IsLoaded reports whether the data was loaded, by this request or by a previous one with the same label.
+func (s StreamLoadResult) IsLoaded() bool {
+	return s.IsSuccess() || s.IsAlreadyLoaded()
+}
+
 func (s StreamLoadResult) Error() error {
 	return fmt.Errorf("error_url=%s message=%s", s.ErrorURL, s.Message)
 }
@@ -0,0 +1,44 @@
+package loader_test
+
+import (
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamLoadResultIsLoaded(t *testing.T) {
+	type testcase struct {
+		TestDescription string
+		Result          loader.StreamLoadResult
+		Expected        bool
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "a successful load should be loaded",
+			Result:          loader.StreamLoadResult{Status: "Success"},
+			Expected:        true,
+		},
+		{
+			TestDescription: "a label loaded by a previous request should be loaded",
+			Result:          loader.StreamLoadResult{Status: "Label Already Exists", ExistingJobStatus: "FINISHED"},
+			Expected:        true,
+		},
+		{
+			TestDescription: "a label still being loaded by a previous request should not be loaded",
+			Result:          loader.StreamLoadResult{Status: "Label Already Exists", ExistingJobStatus: "RUNNING"},
+			Expected:        false,
+		},
+		{
+			TestDescription: "a failed load should not be loaded",
+			Result:          loader.StreamLoadResult{Status: "Fail"},
+			Expected:        false,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+		assert.Equal(t, tc.Expected, tc.Result.IsLoaded())
+	}
+}
@@ -0,0 +1,286 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/internal/dorisconv"
+)
+
+// RejectedRow is a row failing the client-side validation against the table schema. It's never sent to Doris.
+type RejectedRow struct {
+	Line   int    // 1-based line number of the row in the payload
+	Reason string // Why the row was rejected
+	RawRow string // Source line of the row
+}
+
+// RejectFunc receives the rows failing the client-side validation.
+type RejectFunc func(row RejectedRow)
+
+// RowValidator checks encoded rows against a table schema the way Doris does in strict mode: value count, NOT NULL, integer ranges, DECIMAL precision, CHAR and VARCHAR length, BOOLEAN, DATE, DATETIME and JSON values. Other types are not checked.
+type RowValidator struct {
+	schema    *TableSchema
+	format    loadformat.Enum
+	separator string
+	delimiter string
+	columns   []string
+	jsonPaths bool
+}
+
+// NewRowValidator creates a validator of the rows encoded by the load format. The stream load header provides the columns, column_separator and line_delimiter of the payload, like Doris would read them.
+func NewRowValidator(
+	schema *TableSchema,
+	format loadformat.Enum,
+	header map[string]any,
+) *RowValidator {
+	validator := &RowValidator{
+		schema:    schema,
+		format:    format,
+		separator: "\t",
+		delimiter: "\n",
+	}
+
+	if separator, ok := header["column_separator"]; ok {
+		validator.separator = fmt.Sprintf("%v", separator)
+	}
+
+	if delimiter, ok := header["line_delimiter"]; ok {
+		validator.delimiter = fmt.Sprintf("%v", delimiter)
+	}
+
+	for _, column := range headerList(header["columns"]) {
+		// Expressions only derive columns from the source columns, which are the plain ones.
+		if !strings.Contains(column, "=") {
+			validator.columns = append(validator.columns, strings.Trim(strings.TrimSpace(column), "`"))
+		}
+	}
+
+	_, validator.jsonPaths = header["jsonpaths"]
+
+	return validator
+}
+
+// RowValidator creates a validator of the rows loaded by the loader, using the cached table schema.
+func (s StreamLoader) RowValidator(ctx context.Context) (*RowValidator, error) {
+	schema, err := s.Schema(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRowValidator(schema, s.LoadFormat, s.Header), nil
+}
+
+// Filter copies the valid rows of the payload to the writer and passes the invalid ones to reject. The header line of CsvWithNames is always copied. It returns the number of accepted and rejected rows.
+func (v *RowValidator) Filter(
+	payload io.Reader,
+	w io.Writer,
+	reject RejectFunc,
+) (int, int, error) {
+	scanner := bufio.NewScanner(payload)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	scanner.Split(splitDelimiter(v.delimiter))
+
+	columns := v.columns
+	accepted, rejected := 0, 0
+	line := 0
+
+	for scanner.Scan() {
+		row := scanner.Text()
+		line++
+
+		if v.format == loadformat.CsvWithNames && line == 1 {
+			columns = strings.Split(row, v.separator)
+			if _, err := io.WriteString(w, row+v.delimiter); err != nil {
+				return accepted, rejected, err
+			}
+			continue
+		}
+
+		if strings.TrimSpace(row) == "" {
+			continue
+		}
+
+		if err := v.validate(row, columns); err != nil {
+			rejected++
+			if reject != nil {
+				reject(RejectedRow{Line: line, Reason: err.Error(), RawRow: row})
+			}
+			continue
+		}
+
+		accepted++
+		if _, err := io.WriteString(w, row+v.delimiter); err != nil {
+			return accepted, rejected, err
+		}
+	}
+
+	return accepted, rejected, scanner.Err()
+}
+
+// validate checks a row mapped by the source columns, which default to the table columns.
+func (v *RowValidator) validate(row string, columns []string) error {
+	if v.format == loadformat.InlineJson {
+		return v.validateJson(row, columns)
+	}
+
+	if len(columns) == 0 {
+		columns = v.schema.ColumnNames()
+	}
+
+	values := strings.Split(row, v.separator)
+	if len(values) != len(columns) {
+		return fmt.Errorf("value count does not match column count. Expect %d, but got %d", len(columns), len(values))
+	}
+
+	for i, name := range columns {
+		column, ok := v.schema.Column(name)
+		if !ok {
+			continue
+		}
+
+		value := &values[i]
+		if values[i] == `\N` {
+			value = nil
+		}
+
+		if err := validateValue(column, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateJson checks the fields of a JSON object whose names match the source columns, treating a missing field as NULL like Doris does. Rows mapped by jsonpaths are only checked for being JSON objects.
+func (v *RowValidator) validateJson(row string, columns []string) error {
+	decoder := json.NewDecoder(strings.NewReader(row))
+	decoder.UseNumber()
+
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return fmt.Errorf("invalid JSON object: %v", err)
+	}
+
+	if v.jsonPaths {
+		return nil
+	}
+
+	for _, column := range v.schema.Columns {
+		if len(columns) > 0 && !containsFold(columns, column.Name) {
+			continue
+		}
+
+		// A missing key is loaded as NULL.
+		var value *string
+		for key, field := range fields {
+			if strings.EqualFold(key, column.Name) {
+				value = dorisconv.JsonText(field)
+				break
+			}
+		}
+
+		if err := validateValue(column, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateValue checks a value of the column. A nil value is NULL.
+func validateValue(column Column, value *string) error {
+	if value == nil {
+		if !column.Nullable {
+			return fmt.Errorf("column(%s) is NOT NULL but got NULL", column.Name)
+		}
+
+		return nil
+	}
+
+	if _, err := dorisconv.Convert(column.convType(), *value); err != nil {
+		return fmt.Errorf("column(%s) value %q is incorrect while convert to %s: %v", column.Name, *value, column.Type, err)
+	}
+
+	return nil
+}
+
+// convType returns the type of the column converting its values.
+func (c Column) convType() dorisconv.Type {
+	return dorisconv.Type{
+		Name:      c.Type,
+		Length:    c.Length,
+		Precision: c.Precision,
+		Scale:     c.Scale,
+	}
+}
+
+// containsFold reports whether the names contain the name, case-insensitively.
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitDelimiter returns a bufio.SplitFunc splitting lines by the delimiter. A trailing carriage return is kept since Doris keeps it too.
+func splitDelimiter(delimiter string) bufio.SplitFunc {
+	sep := []byte(delimiter)
+
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+
+		if i := bytes.Index(data, sep); i >= 0 {
+			return i + len(sep), data[:i], nil
+		}
+
+		if atEOF {
+			return len(data), data, nil
+		}
+
+		return 0, nil, nil
+	}
+}
+
+// validateRows filters the payload by the RowValidator of the loader when a RejectFunc is set, so that only valid rows are sent.
+func (s StreamLoader) validateRows(ctx context.Context, payload io.ReadSeeker) (io.ReadSeeker, error) {
+	if s.RejectRow == nil {
+		return payload, nil
+	}
+
+	validator, err := s.RowValidator(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := payload.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var filtered bytes.Buffer
+	accepted, rejected, err := validator.Filter(payload, &filtered, s.RejectRow)
+	if err != nil {
+		return nil, err
+	}
+
+	if rejected > 0 {
+		s.log(ctx, slog.LevelWarn, "rows rejected by validation", slog.Int("accepted", accepted), slog.Int("rejected", rejected))
+	}
+
+	if accepted == 0 {
+		return nil, ErrNoValidRows
+	}
+
+	return bytes.NewReader(filtered.Bytes()), nil
+}
@@ -0,0 +1,154 @@
+package loader_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+var validatorSchema = &loader.TableSchema{
+	Database: "test_db",
+	Table:    "users",
+	KeysType: "DUP_KEYS",
+	Columns: []loader.Column{
+		{Name: "name", Type: "VARCHAR", Length: 8},
+		{Name: "age", Type: "TINYINT", AggregationType: "NONE", Nullable: true},
+		{Name: "balance", Type: "DECIMAL64", AggregationType: "NONE", Precision: 5, Scale: 2},
+		{Name: "birthday", Type: "DATEV2", AggregationType: "NONE", Nullable: true},
+	},
+}
+
+func TestRowValidator(t *testing.T) {
+	type testcase struct {
+		TestDescription  string
+		Format           loadformat.Enum
+		Header           map[string]any
+		Payload          string
+		ExpectedPayload  string
+		ExpectedAccepted int
+		ExpectedReasons  []string
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "valid JSON rows should be accepted and invalid ones rejected with the reason",
+			Format:          loadformat.InlineJson,
+			Payload: `{"name": "John", "age": 30, "balance": 12.5, "birthday": "1990-01-02"}
+{"name": "Jonathan Doe", "age": 30, "balance": 1}
+{"name": "Jane", "age": 300, "balance": 1}
+{"name": "Jane", "balance": 1000}
+{"name": "Jane", "balance": 1, "birthday": "1990-13-01"}
+{"name": null, "balance": 1}
+{"age": 30, "balance": 1}
+not json
+`,
+			ExpectedPayload: `{"name": "John", "age": 30, "balance": 12.5, "birthday": "1990-01-02"}
+`,
+			ExpectedAccepted: 1,
+			ExpectedReasons: []string{
+				`column(name) value "Jonathan Doe" is incorrect while convert to VARCHAR: length 12 exceeds 8`,
+				`column(age) value "300" is incorrect while convert to TINYINT: value out of range`,
+				`column(balance) value "1000" is incorrect while convert to DECIMAL64: value out of range of DECIMAL(5, 2)`,
+				`column(birthday) value "1990-13-01" is incorrect while convert to DATEV2: invalid date`,
+				`column(name) is NOT NULL but got NULL`,
+				`column(name) is NOT NULL but got NULL`,
+				`invalid JSON object: invalid character 'o' in literal null (expecting 'u')`,
+			},
+		},
+		{
+			TestDescription:  "a missing key should only be NULL for the columns selected by the header",
+			Format:           loadformat.InlineJson,
+			Header:           map[string]any{"columns": "name,age"},
+			Payload:          "{\"name\": \"John\"}\n{\"age\": 30}\n",
+			ExpectedPayload:  "{\"name\": \"John\"}\n",
+			ExpectedAccepted: 1,
+			ExpectedReasons: []string{
+				`column(name) is NOT NULL but got NULL`,
+			},
+		},
+		{
+			TestDescription:  "CSV rows should be mapped by the columns and separator of the header",
+			Format:           loadformat.Csv,
+			Header:           map[string]any{"columns": "age,name,balance", "column_separator": ","},
+			Payload:          "30,John,1.5\n\\N,Jane,2\nabc,John,1\n30,John\n",
+			ExpectedPayload:  "30,John,1.5\n\\N,Jane,2\n",
+			ExpectedAccepted: 2,
+			ExpectedReasons: []string{
+				`column(age) value "abc" is incorrect while convert to TINYINT: invalid syntax`,
+				`value count does not match column count. Expect 3, but got 2`,
+			},
+		},
+		{
+			TestDescription:  "CSV rows with names should be mapped by the header line",
+			Format:           loadformat.CsvWithNames,
+			Header:           map[string]any{"column_separator": ","},
+			Payload:          "balance,name\n1,John\n1,\\N\n",
+			ExpectedPayload:  "balance,name\n1,John\n",
+			ExpectedAccepted: 1,
+			ExpectedReasons: []string{
+				`column(name) is NOT NULL but got NULL`,
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		validator := loader.NewRowValidator(validatorSchema, tc.Format, tc.Header)
+
+		var reasons []string
+		var filtered bytes.Buffer
+		accepted, rejected, err := validator.Filter(strings.NewReader(tc.Payload), &filtered, func(row loader.RejectedRow) {
+			reasons = append(reasons, row.Reason)
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, tc.ExpectedPayload, filtered.String())
+		assert.Equal(t, tc.ExpectedReasons, reasons)
+		assert.Equal(t, len(tc.ExpectedReasons), rejected)
+		assert.Equal(t, tc.ExpectedAccepted, accepted)
+	}
+}
+
+func TestRowValidation(t *testing.T) {
+	t.Log("only the valid rows should be sent and the invalid ones passed to the reject callback")
+
+	server := newSchemaFe(t)
+
+	var rejected []loader.RejectedRow
+	ld, err := loader.NewStreamLoader(
+		[]string{server.Host()},
+		"test_db",
+		"users",
+		loader.WithMaxFilterRatio(0),
+		loader.WithRowValidation(func(row loader.RejectedRow) {
+			rejected = append(rejected, row)
+		}),
+	)
+	assert.NoError(t, err)
+
+	payload := `{"name": "John Doe", "age": 30, "balance": 1.25}
+{"name": "Jane Doe", "age": "thirty", "balance": 1}
+`
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(payload), "")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	if assert.Len(t, server.Loads(), 1) {
+		assert.Equal(t, "{\"name\": \"John Doe\", \"age\": 30, \"balance\": 1.25}\n", string(server.Loads()[0].Payload))
+	}
+	assert.Equal(t, []loader.RejectedRow{{
+		Line:   2,
+		Reason: `column(age) value "thirty" is incorrect while convert to INT: invalid syntax`,
+		RawRow: `{"name": "Jane Doe", "age": "thirty", "balance": 1}`,
+	}}, rejected)
+
+	t.Log("a payload without any valid row should not be sent")
+
+	_, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "Jane Doe", "balance": null}`), "")
+	assert.ErrorIs(t, err, loader.ErrNoValidRows)
+}
@@ -0,0 +1,343 @@
+package loader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Column is a column of a Doris table.
+type Column struct {
+	Name            string // Column name
+	Type            string // Primitive type without parameters (e.g VARCHAR, DECIMAL)
+	Key             bool   // Whether the column is a key column
+	AggregationType string // Aggregation type, empty for the key columns of AGG_KEYS tables
+	Comment         string // Column comment
+	Nullable        bool   // Whether the column accepts NULL
+	Length          int    // Length of CHAR and VARCHAR, 0 if the FE doesn't report it
+	Precision       int    // Precision of DECIMAL
+	Scale           int    // Scale of DECIMAL
+}
+
+// IsKey reports whether the column is a key column.
+func (c Column) IsKey() bool {
+	return c.Key
+}
+
+// TableSchema is the schema of a Doris table fetched from the FE.
+type TableSchema struct {
+	Database string   // Database name
+	Table    string   // Table name
+	KeysType string   // Data model of the table (e.g DUP_KEYS, UNIQUE_KEYS, AGG_KEYS)
+	Columns  []Column // Columns in table order
+}
+
+// Column returns the column with the name, which is case-insensitive like in Doris.
+func (t TableSchema) Column(name string) (Column, bool) {
+	for _, column := range t.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return column, true
+		}
+	}
+
+	return Column{}, false
+}
+
+// ColumnNames returns the names of the columns in table order.
+func (t TableSchema) ColumnNames() []string {
+	names := make([]string, 0, len(t.Columns))
+	for _, column := range t.Columns {
+		names = append(names, column.Name)
+	}
+
+	return names
+}
+
+// schemaResponse is the response body of the FE table schema API.
+type schemaResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		KeysType   string `json:"keysType"`
+		Properties []struct {
+			Name            string `json:"name"`
+			Type            string `json:"type"`
+			IsKey           string `json:"is_key"`
+			AggregationType string `json:"aggregation_type"`
+			Comment         string `json:"comment"`
+			IsNullable      string `json:"is_nullable"`
+			Precision       string `json:"precision"`
+			Scale           string `json:"scale"`
+			Length          string `json:"length"`
+		} `json:"properties"`
+	} `json:"data"`
+}
+
+// schemaCache holds the schema fetched by Schema, shared by the copies of a loader.
+type schemaCache struct {
+	mu     sync.Mutex
+	schema *TableSchema
+}
+
+var typeParameterPattern = regexp.MustCompile(`^(\w+)\((\d+)(?:,\s*(\d+))?\)$`)
+
+// Schema returns the schema of the loaded table. It's fetched from the FE on the first call and cached afterward.
+func (s StreamLoader) Schema(ctx context.Context) (*TableSchema, error) {
+	if s.schema == nil {
+		return s.FetchSchema(ctx)
+	}
+
+	s.schema.mu.Lock()
+	defer s.schema.mu.Unlock()
+
+	if s.schema.schema != nil {
+		return s.schema.schema, nil
+	}
+
+	schema, err := s.FetchSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.schema.schema = schema
+
+	return schema, nil
+}
+
+// FetchSchema fetches the schema of the loaded table from the FE /api/{db}/{table}/_schema API. The FE nodes are tried in turn until one of them answers.
+func (s StreamLoader) FetchSchema(ctx context.Context) (*TableSchema, error) {
+	var err error
+
+	for _, feNode := range s.frontends().Next() {
+		var schema *TableSchema
+		if schema, err = s.fetchSchema(ctx, feNode); err == nil {
+			return schema, nil
+		}
+	}
+
+	return nil, err
+}
+
+// fetchSchema fetches the schema of the loaded table from the FE node.
+func (s StreamLoader) fetchSchema(ctx context.Context, feNode string) (*TableSchema, error) {
+	url := fmt.Sprintf("%s://%s/api/%s/%s/_schema", s.Protocol, feNode, s.Database, s.Table)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := s.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(credentials.Username, credentials.Password)
+
+	res, err := s.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, ErrUnauthorized
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("schema status: %s", res.Status)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response schemaResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+
+	if response.Code != 0 {
+		return nil, fmt.Errorf("schema code=%d msg=%s", response.Code, response.Msg)
+	}
+
+	schema := &TableSchema{
+		Database: s.Database,
+		Table:    s.Table,
+		KeysType: response.Data.KeysType,
+	}
+
+	for _, property := range response.Data.Properties {
+		column := Column{
+			Name:            property.Name,
+			Type:            strings.ToUpper(property.Type),
+			Key:             isKey(response.Data.KeysType, property.IsKey, property.AggregationType),
+			AggregationType: property.AggregationType,
+			Comment:         property.Comment,
+			Nullable:        strings.EqualFold(property.IsNullable, "Yes"),
+			Length:          atoi(property.Length),
+			Precision:       atoi(property.Precision),
+			Scale:           atoi(property.Scale),
+		}
+
+		// Some versions report the parameters in the type, like VARCHAR(20) or DECIMAL(10, 2).
+		if match := typeParameterPattern.FindStringSubmatch(column.Type); match != nil {
+			column.Type = match[1]
+			if match[3] != "" {
+				column.Precision, column.Scale = atoi(match[2]), atoi(match[3])
+			} else if strings.HasPrefix(column.Type, "DECIMAL") {
+				column.Precision = atoi(match[2])
+			} else {
+				column.Length = atoi(match[2])
+			}
+		}
+
+		schema.Columns = append(schema.Columns, column)
+	}
+
+	return schema, nil
+}
+
+// isKey reports whether a column of the schema API is a key column. FE versions reporting is_key are trusted. Otherwise only the columns of AGG_KEYS tables can be told apart, by their empty aggregation type, since the value columns of DUP_KEYS and UNIQUE_KEYS tables may have an empty one too.
+func isKey(keysType string, flag string, aggregationType string) bool {
+	if flag != "" {
+		return strings.EqualFold(flag, "Yes") || strings.EqualFold(flag, "true")
+	}
+
+	return keysType == "AGG_KEYS" && aggregationType == ""
+}
+
+// validateSchema fetches the schema within SchemaTimeout and checks the configured columns, load format and partial update settings against it.
+func (s StreamLoader) validateSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.SchemaTimeout)
+	defer cancel()
+
+	schema, err := s.Schema(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.checkSchema(schema)
+}
+
+// checkSchema checks the configured columns, load format and partial update settings against the schema. Every mismatch is reported.
+func (s StreamLoader) checkSchema(schema *TableSchema) error {
+	var errs []error
+
+	columns := headerList(s.Header["columns"])
+	var plain, targets, expressions []string
+	for _, column := range columns {
+		name, expression, ok := strings.Cut(column, "=")
+		name = strings.Trim(strings.TrimSpace(name), "`")
+		if ok {
+			targets = append(targets, name)
+			expressions = append(expressions, expression)
+		} else {
+			plain = append(plain, name)
+		}
+	}
+
+	for _, name := range targets {
+		if _, ok := schema.Column(name); !ok {
+			errs = append(errs, ErrSchemaMismatch("Columns", fmt.Sprintf("column %s does not exist in %s.%s", name, schema.Database, schema.Table)))
+		}
+	}
+
+	for _, name := range plain {
+		if _, ok := schema.Column(name); ok {
+			continue
+		}
+
+		// Columns missing from the table may still be placeholders used by the expressions.
+		placeholder := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+		used := false
+		for _, expression := range expressions {
+			used = used || placeholder.MatchString(expression)
+		}
+
+		if !used {
+			errs = append(errs, ErrSchemaMismatch("Columns", fmt.Sprintf("column %s does not exist in %s.%s", name, schema.Database, schema.Table)))
+		}
+	}
+
+	if jsonPaths, ok := s.Header["jsonpaths"]; ok && len(columns) > 0 {
+		var paths []string
+		if err := json.Unmarshal([]byte(fmt.Sprintf("%v", jsonPaths)), &paths); err != nil {
+			errs = append(errs, ErrSchemaMismatch("LoadFormat", "jsonpaths is not a JSON array"))
+		} else if len(paths) != len(plain) {
+			errs = append(errs, ErrSchemaMismatch("LoadFormat", fmt.Sprintf("%d jsonpaths do not match %d columns", len(paths), len(plain))))
+		}
+	}
+
+	if strings.EqualFold(fmt.Sprintf("%v", s.Header["partial_columns"]), "true") {
+		if schema.KeysType != "UNIQUE_KEYS" {
+			errs = append(errs, ErrSchemaMismatch("PartialColumns", fmt.Sprintf("partial update requires a UNIQUE_KEYS table, got %s", schema.KeysType)))
+		}
+
+		if len(columns) == 0 {
+			errs = append(errs, ErrSchemaMismatch("PartialColumns", "partial update requires columns"))
+		}
+
+		named := append(append([]string{}, plain...), targets...)
+		for _, column := range schema.Columns {
+			if !column.IsKey() {
+				continue
+			}
+
+			included := false
+			for _, name := range named {
+				included = included || strings.EqualFold(name, column.Name)
+			}
+
+			if !included && len(columns) > 0 {
+				errs = append(errs, ErrSchemaMismatch("PartialColumns", fmt.Sprintf("key column %s is missing from columns", column.Name)))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// headerList splits a comma separated header value. Commas inside parentheses, such as function arguments, are kept.
+func headerList(value any) []string {
+	if value == nil {
+		return nil
+	}
+
+	var items []string
+	var current strings.Builder
+	depth := 0
+
+	for _, r := range fmt.Sprintf("%v", value) {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			items = append(items, strings.TrimSpace(current.String()))
+			current.Reset()
+			continue
+		}
+
+		current.WriteRune(r)
+	}
+
+	if item := strings.TrimSpace(current.String()); item != "" {
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// atoi converts the string to int, returning 0 if it's not a number.
+func atoi(value string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(value))
+	return n
+}
@@ -0,0 +1,192 @@
+package loader_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/enum/loadformat"
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSchemaFe(t *testing.T) *doristest.Server {
+	t.Helper()
+
+	return doristest.NewServer(t, doristest.WithRoute("/api/test_db/users/_schema", doristest.Response{Body: `{
+		"msg": "success",
+		"code": 0,
+		"data": {
+			"properties": [
+				{"name": "name", "type": "VARCHAR(50)", "is_key": "Yes", "aggregation_type": "", "comment": "", "is_nullable": "Yes"},
+				{"name": "age", "type": "INT", "is_key": "No", "aggregation_type": "NONE", "comment": "", "is_nullable": "Yes"},
+				{"name": "balance", "type": "DECIMAL64", "precision": "10", "scale": "2", "is_key": "No", "aggregation_type": "NONE", "comment": "", "is_nullable": "No"}
+			],
+			"keysType": "DUP_KEYS",
+			"status": 200
+		},
+		"count": 0
+	}`}))
+}
+
+func TestSchema(t *testing.T) {
+	t.Log("the table schema should be fetched from the FE")
+
+	server := newSchemaFe(t)
+
+	ld, err := loader.NewStreamLoader([]string{server.Host()}, "test_db", "users")
+	assert.NoError(t, err)
+
+	schema, err := ld.Schema(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "DUP_KEYS", schema.KeysType)
+	assert.Equal(t, []string{"name", "age", "balance"}, schema.ColumnNames())
+	assert.Equal(t, loader.Column{Name: "name", Type: "VARCHAR", Key: true, Nullable: true, Length: 50}, schema.Columns[0])
+	assert.Equal(t, loader.Column{Name: "balance", Type: "DECIMAL64", AggregationType: "NONE", Precision: 10, Scale: 2}, schema.Columns[2])
+}
+
+func TestSchemaValidation(t *testing.T) {
+	server := newSchemaFe(t)
+
+	type testcase struct {
+		TestDescription string
+		Options         []loader.StreamLoaderOption
+		ExpectedError   string
+	}
+
+	testcases := []testcase{
+		{
+			TestDescription: "columns of the table should pass the validation",
+			Options: []loader.StreamLoaderOption{
+				loader.WithLoadFormat(loadformat.Csv),
+				loader.WithColumns([]string{"name", "age"}),
+			},
+		},
+		{
+			TestDescription: "placeholder columns used by expressions should pass the validation",
+			Options: []loader.StreamLoaderOption{
+				loader.WithLoadFormat(loadformat.Csv),
+				loader.WithColumns([]string{"name", "tmp_age", "age=tmp_age+1"}),
+			},
+		},
+		{
+			TestDescription: "a typo in columns should fail the validation",
+			Options: []loader.StreamLoaderOption{
+				loader.WithLoadFormat(loadformat.Csv),
+				loader.WithColumns([]string{"name", "aeg"}),
+			},
+			ExpectedError: loader.ErrSchemaMismatch("Columns", "column aeg does not exist in test_db.users").Error(),
+		},
+		{
+			TestDescription: "jsonpaths should match the columns",
+			Options: []loader.StreamLoaderOption{
+				loader.WithColumns([]string{"name", "age"}),
+				loader.WithHeader(map[string]any{"jsonpaths": `["$.name"]`}),
+			},
+			ExpectedError: loader.ErrSchemaMismatch("LoadFormat", "1 jsonpaths do not match 2 columns").Error(),
+		},
+		{
+			TestDescription: "partial update should require a unique key table",
+			Options: []loader.StreamLoaderOption{
+				loader.WithColumns([]string{"name", "age"}),
+				loader.WithHeader(map[string]any{"partial_columns": "true"}),
+			},
+			ExpectedError: loader.ErrSchemaMismatch("PartialColumns", "partial update requires a UNIQUE_KEYS table, got DUP_KEYS").Error(),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Log(tc.TestDescription)
+
+		options := append([]loader.StreamLoaderOption{loader.WithSchemaValidation()}, tc.Options...)
+		_, err := loader.NewStreamLoader([]string{server.Host()}, "test_db", "users", options...)
+
+		if tc.ExpectedError == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.ErrorIs(t, err, loader.ErrSchemaValidation)
+			assert.ErrorContains(t, err, tc.ExpectedError)
+		}
+	}
+}
+
+func TestSchemaValidationKeyColumns(t *testing.T) {
+	t.Log("partial update should require the key columns reported by the FE, not the value columns without an aggregation type")
+
+	server, err := dorisfake.NewServer(dorisfake.WithTable(loader.TableSchema{
+		Database: "test_db",
+		Table:    "users",
+		KeysType: "UNIQUE_KEYS",
+		Columns: []loader.Column{
+			{Name: "id", Type: "INT", Key: true},
+			{Name: "name", Type: "VARCHAR", Length: 50, Nullable: true},
+			{Name: "age", Type: "INT", Nullable: true},
+		},
+	}))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"))
+	assert.NoError(t, err)
+
+	schema, err := ld.Schema(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, schema.Columns[0].IsKey())
+	assert.False(t, schema.Columns[1].IsKey())
+
+	_, err = loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithSchemaValidation(),
+		loader.WithColumns([]string{"id", "name"}),
+		loader.WithHeader(map[string]any{"partial_columns": "true"}),
+	)
+	assert.NoError(t, err)
+
+	_, err = loader.NewStreamLoader(
+		server.FeNodes(),
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithSchemaValidation(),
+		loader.WithColumns([]string{"name"}),
+		loader.WithHeader(map[string]any{"partial_columns": "true"}),
+	)
+	assert.EqualError(t, err, loader.ErrSchemaValidation.Error()+": "+loader.ErrSchemaMismatch("PartialColumns", "key column id is missing from columns").Error())
+}
+
+func TestWithSchemaTimeout(t *testing.T) {
+	t.Log("schema validation should give up on an FE which doesn't answer within the schema timeout")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	start := time.Now()
+	_, err := loader.NewStreamLoader(
+		[]string{strings.TrimPrefix(server.URL, "http://")},
+		"test_db",
+		"users",
+		loader.WithSchemaValidation(),
+		loader.WithSchemaTimeout(50*time.Millisecond),
+	)
+	assert.ErrorIs(t, err, loader.ErrSchemaValidation)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	t.Log("a non-positive timeout or a timeout set twice should be rejected")
+
+	_, err = loader.NewStreamLoader([]string{"127.0.0.1:8030"}, "test_db", "users", loader.WithSchemaTimeout(0))
+	assert.ErrorContains(t, err, loader.ErrUnsupportValue("SchemaTimeout").Error())
+
+	_, err = loader.NewStreamLoader([]string{"127.0.0.1:8030"}, "test_db", "users", loader.WithSchemaTimeout(time.Second), loader.WithSchemaTimeout(time.Minute))
+	assert.ErrorContains(t, err, loader.ErrAmbiguousOption("SchemaTimeout").Error())
+}
@@ -0,0 +1,170 @@
+package loader
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope of the spans created by the loader.
+const tracerName = "github.com/raaaaaaaay86/doris-loader/loader"
+
+// Names of the spans created by the loader. A load span is the parent of its attempt spans, and an attempt span is the parent of the FE request span and the BE redirect span.
+const (
+	spanLoad       = "doris.stream_load"
+	spanAttempt    = "doris.stream_load.attempt"
+	spanFeRequest  = "doris.stream_load.fe_request"
+	spanBeRedirect = "doris.stream_load.be_redirect"
+)
+
+// tracer returns the tracer of the TracerProvider, or a no-op tracer if tracing is disabled.
+func (s StreamLoader) tracer() trace.Tracer {
+	if s.TracerProvider == nil {
+		return noop.NewTracerProvider().Tracer(tracerName)
+	}
+
+	return s.TracerProvider.Tracer(tracerName)
+}
+
+// transport returns the transport of stream load requests. It creates a span for each request and propagates the W3C trace context in its headers if tracing is enabled.
+func (s StreamLoader) transport() http.RoundTripper {
+	if s.TracerProvider == nil {
+		return s.httpTransport()
+	}
+
+	return &tracingTransport{
+		base:   s.httpTransport(),
+		tracer: s.tracer(),
+	}
+}
+
+// tracingTransport creates a client span for each request sent by the http.Client of doRequest.
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// http.Client sets the response causing the redirect on the redirected requests only.
+	name := spanFeRequest
+	if req.Response != nil {
+		name = spanBeRedirect
+	}
+
+	ctx, span := t.tracer.Start(req.Context(), name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Host),
+			attribute.String("url.full", redactURL(req.URL)),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", res.StatusCode))
+	if res.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, res.Status)
+	}
+
+	return res, nil
+}
+
+// endSpan records the result or error of a load or an attempt on the span and ends it.
+func endSpan(span trace.Span, result *StreamLoadResult, err error) {
+	defer span.End()
+
+	if result != nil {
+		span.SetAttributes(resultSpanAttrs(result)...)
+	}
+
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case result != nil && !result.IsLoaded():
+		span.SetStatus(codes.Error, result.Message)
+	}
+}
+
+// resultSpanAttrs returns the span attributes describing a stream load result, including the Doris timing breakdown.
+func resultSpanAttrs(result *StreamLoadResult) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("doris.label", result.Label),
+		attribute.String("doris.status", result.Status),
+		attribute.Int("doris.txn_id", result.TxnId),
+		attribute.Int("doris.rows.total", result.NumberTotalRows),
+		attribute.Int("doris.rows.loaded", result.NumberLoadedRows),
+		attribute.Int("doris.rows.filtered", result.NumberFilteredRows),
+		attribute.Int("doris.rows.unselected", result.NumberUnselectedRows),
+		attribute.Int("doris.load_bytes", result.LoadBytes),
+		attribute.Int("doris.load_time_ms", result.LoadTimeMs),
+		attribute.Int("doris.begin_txn_time_ms", result.BeginTxnTimeMs),
+		attribute.Int("doris.stream_load_put_time_ms", result.StreamLoadPutTimeMs),
+		attribute.Int("doris.read_data_time_ms", result.ReadDataTimeMs),
+		attribute.Int("doris.write_data_time_ms", result.WriteDataTimeMs),
+		attribute.Int("doris.commit_and_publish_time_ms", result.CommitAndPublishTimeMs),
+	}
+
+	if result.ErrorURL != "" {
+		attrs = append(attrs, attribute.String("doris.error_url", result.ErrorURL))
+	}
+
+	return attrs
+}
+
+// loadTracing is the built-in middleware creating the parent span of a load.
+func (s StreamLoader) loadTracing(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		state := loadStateFrom(req.Context())
+		ctx, span := s.tracer().Start(req.Context(), spanLoad,
+			trace.WithAttributes(
+				attribute.String("db.system.name", "doris"),
+				attribute.String("db.namespace", s.Database),
+				attribute.String("db.collection.name", s.Table),
+				attribute.String("doris.label", req.Header.Get("label")),
+				attribute.Int64("doris.payload_bytes", state.bytes),
+			),
+		)
+
+		result, err := next(req.WithContext(ctx))
+
+		span.SetAttributes(attribute.Int("doris.attempts", state.attempt))
+		endSpan(span, result, err)
+
+		return result, err
+	}
+}
+
+// attemptTracing is the built-in middleware creating the span of each attempt, which is the parent of the spans created by tracingTransport.
+func (s StreamLoader) attemptTracing(next RoundTrip) RoundTrip {
+	return func(req *http.Request) (*StreamLoadResult, error) {
+		state := loadStateFrom(req.Context())
+		ctx, span := s.tracer().Start(req.Context(), spanAttempt,
+			trace.WithAttributes(
+				attribute.String("doris.fe", req.URL.Host),
+				attribute.Int("doris.attempt", state.attempt),
+			),
+		)
+
+		result, err := next(req.WithContext(ctx))
+
+		span.SetAttributes(attribute.String("doris.be", state.beNode))
+		endSpan(span, result, err)
+
+		return result, err
+	}
+}
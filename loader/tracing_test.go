@@ -0,0 +1,130 @@
+package loader_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/internal/doristest"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	t.Log("a load should create a span with attempt, FE request and BE redirect spans, and propagate the trace context")
+
+	be := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		return doristest.Response{Body: `{
+			"Label": "trace_label",
+			"Status": "Success",
+			"NumberTotalRows": 1,
+			"NumberLoadedRows": 1,
+			"LoadTimeMs": 120,
+			"BeginTxnTimeMs": 2,
+			"StreamLoadPutTimeMs": 5,
+			"ReadDataTimeMs": 10,
+			"WriteDataTimeMs": 80,
+			"CommitAndPublishTimeMs": 20
+		}`}
+	}))
+
+	attempts := 0
+	fe := doristest.NewServer(t, doristest.WithResponder(func(load doristest.Load) doristest.Response {
+		attempts++
+		if attempts == 1 {
+			return doristest.Response{Status: http.StatusServiceUnavailable}
+		}
+
+		return doristest.Response{Status: http.StatusTemporaryRedirect, Location: be.URL + load.Path}
+	}))
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe.Host()},
+		"test_db",
+		"users",
+		loader.WithUsername("root"),
+		loader.WithPassword("s3cret"),
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithTracerProvider(provider),
+	)
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "trace_label")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+
+	spans := exporter.GetSpans()
+	byName := map[string][]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = append(byName[span.Name], span)
+	}
+
+	assert.Len(t, byName["doris.stream_load"], 1)
+	assert.Len(t, byName["doris.stream_load.attempt"], 2)
+	assert.Len(t, byName["doris.stream_load.fe_request"], 2)
+	assert.Len(t, byName["doris.stream_load.be_redirect"], 1)
+	if t.Failed() {
+		return
+	}
+
+	load := byName["doris.stream_load"][0]
+	assert.Equal(t, codes.Unset, load.Status.Code)
+	assert.Contains(t, load.Attributes, attribute.String("doris.label", "trace_label"))
+	assert.Contains(t, load.Attributes, attribute.Int("doris.attempts", 2))
+	assert.Contains(t, load.Attributes, attribute.Int("doris.write_data_time_ms", 80))
+	assert.Contains(t, load.Attributes, attribute.Int("doris.commit_and_publish_time_ms", 20))
+
+	failed, succeeded := byName["doris.stream_load.attempt"][0], byName["doris.stream_load.attempt"][1]
+	if failed.EndTime.After(succeeded.EndTime) {
+		failed, succeeded = succeeded, failed
+	}
+	assert.Equal(t, codes.Error, failed.Status.Code)
+	assert.Contains(t, succeeded.Attributes, attribute.Int("doris.attempt", 2))
+	assert.Contains(t, succeeded.Attributes, attribute.String("doris.be", be.Host()))
+	assert.Contains(t, succeeded.Attributes, attribute.Int("doris.load_time_ms", 120))
+
+	for _, span := range spans {
+		assert.Equal(t, load.SpanContext.TraceID(), span.SpanContext.TraceID())
+		for _, attr := range span.Attributes {
+			assert.NotContains(t, attr.Value.Emit(), "s3cret")
+		}
+	}
+	for _, attempt := range byName["doris.stream_load.attempt"] {
+		assert.Equal(t, load.SpanContext.SpanID(), attempt.Parent.SpanID())
+	}
+
+	redirect := byName["doris.stream_load.be_redirect"][0]
+	assert.Equal(t, succeeded.SpanContext.SpanID(), redirect.Parent.SpanID())
+	assert.Contains(t, redirect.Attributes, attribute.String("server.address", be.Host()))
+
+	t.Log("every request should carry the trace context of its own span")
+	assert.Len(t, fe.Loads(), 2)
+	if assert.Len(t, be.Loads(), 1) {
+		assert.Equal(t, "00-"+load.SpanContext.TraceID().String()+"-"+redirect.SpanContext.SpanID().String()+"-01", be.Loads()[0].Header.Get("traceparent"))
+	}
+}
+
+func TestTracingDisabled(t *testing.T) {
+	t.Log("no trace context should be propagated without a tracer provider")
+
+	server := doristest.NewServer(t)
+
+	ld, err := loader.NewStreamLoader([]string{server.Host()}, "test_db", "users")
+	assert.NoError(t, err)
+
+	_, err = ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "")
+	assert.NoError(t, err)
+	if assert.Len(t, server.Loads(), 1) {
+		assert.Empty(t, server.Loads()[0].Header.Get("traceparent"))
+	}
+}
@@ -0,0 +1,17 @@
+package loader
+
+import "net/http"
+
+// httpTransport returns the Transport, or http.DefaultTransport if none is set.
+func (s StreamLoader) httpTransport() http.RoundTripper {
+	if s.Transport == nil {
+		return http.DefaultTransport
+	}
+
+	return s.Transport
+}
+
+// httpClient returns the client of the requests other than stream loads: health checks, BE discovery, schema and error log fetching.
+func (s StreamLoader) httpClient() *http.Client {
+	return &http.Client{Transport: s.httpTransport()}
+}
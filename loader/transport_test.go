@@ -0,0 +1,74 @@
+package loader_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/dorisfake"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingTransport counts the requests sent through it by path, and the most requests in flight at once.
+type countingTransport struct {
+	mu          sync.Mutex
+	paths       map[string]int
+	inflight    int
+	maxInflight int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.paths[req.URL.Path]++
+	c.inflight++
+	c.maxInflight = max(c.maxInflight, c.inflight)
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.inflight--
+		c.mu.Unlock()
+	}()
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWithTransport(t *testing.T) {
+	t.Log("stream loads and schema requests should be sent through the transport")
+
+	server, err := dorisfake.NewServer(dorisfake.WithTable(loader.TableSchema{
+		Database: "test_db",
+		Table:    "users",
+		Columns:  []loader.Column{{Name: "name", Type: "VARCHAR", Nullable: true}},
+	}))
+	assert.NoError(t, err)
+	t.Cleanup(server.Close)
+
+	transport := &countingTransport{paths: map[string]int{}}
+	ld, err := loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithUsername("root"), loader.WithTransport(transport))
+	assert.NoError(t, err)
+
+	result, err := ld.LoadReader(context.Background(), strings.NewReader(`{"name": "John Doe"}`), "transport_label")
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+
+	_, err = ld.FetchSchema(context.Background())
+	assert.NoError(t, err)
+
+	// The stream load is sent to the FE and then to the BE it redirects to.
+	assert.Equal(t, map[string]int{
+		"/api/test_db/users/_stream_load": 2,
+		"/api/test_db/users/_schema":      1,
+	}, transport.paths)
+
+	t.Log("a nil transport or a transport set twice should be rejected")
+
+	_, err = loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithTransport(nil))
+	assert.ErrorContains(t, err, loader.ErrZeroValueOption("Transport").Error())
+
+	_, err = loader.NewStreamLoader(server.FeNodes(), "test_db", "users", loader.WithTransport(transport), loader.WithTransport(transport))
+	assert.ErrorContains(t, err, loader.ErrAmbiguousOption("Transport").Error())
+}
@@ -0,0 +1,178 @@
+// Package prommetrics exposes the measurements of the loader as Prometheus collectors.
+package prommetrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+)
+
+// Recorder is a loader.MetricsRecorder and a prometheus.Collector. Register it to a registry and pass it to loader.WithMetricsRecorder.
+//
+//	recorder := prommetrics.NewRecorder()
+//	prometheus.MustRegister(recorder)
+//
+//	ld, err := loader.NewStreamLoader(feNodes, "db_name", "table_name", loader.WithMetricsRecorder(recorder))
+type Recorder struct {
+	attempts        *prometheus.CounterVec
+	attemptDuration *prometheus.HistogramVec
+	loads           *prometheus.CounterVec
+	loadDuration    *prometheus.HistogramVec
+	retries         *prometheus.CounterVec
+	bytes           *prometheus.CounterVec
+	rows            *prometheus.CounterVec
+	phaseDuration   *prometheus.HistogramVec
+}
+
+// Outcomes of attempts and loads.
+const (
+	outcomeSuccess = "success" // Doris loaded the data, including by a previous attempt with the same label
+	outcomeFail    = "fail"    // Doris answered with a failed status
+	outcomeError   = "error"   // The request failed before Doris answered
+)
+
+// phases maps the phase label to the Doris timing of the result.
+var phases = []struct {
+	name   string
+	timing func(result *loader.StreamLoadResult) int
+}{
+	{"begin_txn", func(r *loader.StreamLoadResult) int { return r.BeginTxnTimeMs }},
+	{"stream_load_put", func(r *loader.StreamLoadResult) int { return r.StreamLoadPutTimeMs }},
+	{"read_data", func(r *loader.StreamLoadResult) int { return r.ReadDataTimeMs }},
+	{"write_data", func(r *loader.StreamLoadResult) int { return r.WriteDataTimeMs }},
+	{"commit_and_publish", func(r *loader.StreamLoadResult) int { return r.CommitAndPublishTimeMs }},
+	{"load", func(r *loader.StreamLoadResult) int { return r.LoadTimeMs }},
+}
+
+// NewRecorder creates a recorder with the metrics named doris_loader_*.
+func NewRecorder() *Recorder {
+	const namespace = "doris_loader"
+
+	return &Recorder{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "attempts_total",
+			Help:      "Stream load requests by table, node and outcome.",
+		}, []string{"database", "table", "fe", "be", "outcome"}),
+		attemptDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "attempt_duration_seconds",
+			Help:      "Duration of stream load requests by table and node.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, []string{"database", "table", "fe", "be"}),
+		loads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "loads_total",
+			Help:      "Loads by table and outcome, including their retries.",
+		}, []string{"database", "table", "outcome"}),
+		loadDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "load_duration_seconds",
+			Help:      "Duration of loads by table, including retries.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, []string{"database", "table"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "retries_total",
+			Help:      "Retried stream load requests by table.",
+		}, []string{"database", "table"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "bytes_total",
+			Help:      "Bytes of the payloads of finished loads by table.",
+		}, []string{"database", "table"}),
+		rows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rows_total",
+			Help:      "Rows reported by Doris by table and state (loaded, filtered or unselected).",
+		}, []string{"database", "table", "state"}),
+		phaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "phase_duration_seconds",
+			Help:      "Doris timing breakdown of loads by table and phase.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 18),
+		}, []string{"database", "table", "phase"}),
+	}
+}
+
+func (r *Recorder) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range r.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+func (r *Recorder) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range r.collectors() {
+		collector.Collect(ch)
+	}
+}
+
+func (r *Recorder) ObserveAttempt(ctx context.Context, attempt loader.AttemptMetrics) {
+	r.attempts.WithLabelValues(attempt.Database, attempt.Table, attempt.FeNode, attempt.BeNode, outcome(attempt.Result, attempt.Err)).Inc()
+	r.attemptDuration.WithLabelValues(attempt.Database, attempt.Table, attempt.FeNode, attempt.BeNode).Observe(attempt.Duration.Seconds())
+}
+
+func (r *Recorder) ObserveLoad(ctx context.Context, load loader.LoadMetrics) {
+	r.loads.WithLabelValues(load.Database, load.Table, outcome(load.Result, load.Err)).Inc()
+	r.loadDuration.WithLabelValues(load.Database, load.Table).Observe(load.Duration.Seconds())
+
+	if load.Attempts > 1 {
+		r.retries.WithLabelValues(load.Database, load.Table).Add(float64(load.Attempts - 1))
+	}
+
+	result := load.Result
+	if result == nil {
+		return
+	}
+
+	r.bytes.WithLabelValues(load.Database, load.Table).Add(float64(load.Bytes))
+
+	for state, count := range map[string]int{
+		"loaded":     result.NumberLoadedRows,
+		"filtered":   result.NumberFilteredRows,
+		"unselected": result.NumberUnselectedRows,
+	} {
+		r.rows.WithLabelValues(load.Database, load.Table, state).Add(float64(count))
+	}
+
+	// Results such as the one of an already loaded label carry no timings.
+	if result.LoadTimeMs == 0 {
+		return
+	}
+
+	for _, phase := range phases {
+		r.phaseDuration.WithLabelValues(load.Database, load.Table, phase.name).Observe(float64(phase.timing(result)) / 1000)
+	}
+}
+
+// collectors returns every collector of the recorder.
+func (r *Recorder) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		r.attempts,
+		r.attemptDuration,
+		r.loads,
+		r.loadDuration,
+		r.retries,
+		r.bytes,
+		r.rows,
+		r.phaseDuration,
+	}
+}
+
+// outcome classifies a result and error as success, fail or error.
+func outcome(result *loader.StreamLoadResult, err error) string {
+	switch {
+	case err != nil || result == nil:
+		return outcomeError
+	case result.IsLoaded():
+		return outcomeSuccess
+	default:
+		return outcomeFail
+	}
+}
+
+var (
+	_ loader.MetricsRecorder = (*Recorder)(nil)
+	_ prometheus.Collector   = (*Recorder)(nil)
+)
@@ -0,0 +1,110 @@
+package prommetrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/raaaaaaaay86/doris-loader/prommetrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Log("attempts, retries, rows, bytes and Doris timings should be exposed to the registry")
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{
+			"Status": "Success",
+			"NumberTotalRows": 3,
+			"NumberLoadedRows": 2,
+			"NumberFilteredRows": 1,
+			"LoadTimeMs": 120,
+			"BeginTxnTimeMs": 2,
+			"StreamLoadPutTimeMs": 5,
+			"ReadDataTimeMs": 10,
+			"WriteDataTimeMs": 80,
+			"CommitAndPublishTimeMs": 20
+		}`))
+	}))
+	t.Cleanup(server.Close)
+	fe := strings.TrimPrefix(server.URL, "http://")
+
+	recorder := prommetrics.NewRecorder()
+	registry := prometheus.NewPedanticRegistry()
+	assert.NoError(t, registry.Register(recorder))
+
+	ld, err := loader.NewStreamLoader(
+		[]string{fe},
+		"test_db",
+		"users",
+		loader.WithRetryInterval(time.Millisecond),
+		loader.WithMetricsRecorder(recorder),
+	)
+	assert.NoError(t, err)
+
+	payload := `{"name": "John Doe"}`
+	_, err = ld.LoadReader(context.Background(), strings.NewReader(payload), "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP doris_loader_attempts_total Stream load requests by table, node and outcome.
+# TYPE doris_loader_attempts_total counter
+doris_loader_attempts_total{be="",database="test_db",fe="`+fe+`",outcome="error",table="users"} 1
+doris_loader_attempts_total{be="",database="test_db",fe="`+fe+`",outcome="success",table="users"} 1
+# HELP doris_loader_loads_total Loads by table and outcome, including their retries.
+# TYPE doris_loader_loads_total counter
+doris_loader_loads_total{database="test_db",outcome="success",table="users"} 1
+# HELP doris_loader_retries_total Retried stream load requests by table.
+# TYPE doris_loader_retries_total counter
+doris_loader_retries_total{database="test_db",table="users"} 1
+# HELP doris_loader_bytes_total Bytes of the payloads of finished loads by table.
+# TYPE doris_loader_bytes_total counter
+doris_loader_bytes_total{database="test_db",table="users"} 20
+# HELP doris_loader_rows_total Rows reported by Doris by table and state (loaded, filtered or unselected).
+# TYPE doris_loader_rows_total counter
+doris_loader_rows_total{database="test_db",state="filtered",table="users"} 1
+doris_loader_rows_total{database="test_db",state="loaded",table="users"} 2
+doris_loader_rows_total{database="test_db",state="unselected",table="users"} 0
+`),
+		"doris_loader_attempts_total",
+		"doris_loader_loads_total",
+		"doris_loader_retries_total",
+		"doris_loader_bytes_total",
+		"doris_loader_rows_total",
+	))
+
+	assert.Equal(t, 6, testutil.CollectAndCount(recorder, "doris_loader_phase_duration_seconds"))
+	assert.Equal(t, 1, testutil.CollectAndCount(recorder, "doris_loader_load_duration_seconds"))
+
+	metrics, err := registry.Gather()
+	assert.NoError(t, err)
+	for _, family := range metrics {
+		if family.GetName() != "doris_loader_phase_duration_seconds" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "phase" && label.GetValue() == "write_data" {
+					assert.Equal(t, 0.08, metric.GetHistogram().GetSampleSum())
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package spool
+
+import "errors"
+
+var (
+	ErrSpoolFull    = errors.New("spool is full")
+	ErrLabelTooLong = errors.New("label is longer than 65535 bytes")
+)
@@ -0,0 +1,139 @@
+package spool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// A segment file holds one batch:
+//
+//	magic "DLSP" | version (1 byte) | label length (uint16) | label | payload length (uint64) | payload | CRC-32 of everything before (uint32)
+//
+// All integers are big endian.
+const (
+	segmentMagic   = "DLSP"
+	segmentVersion = 1
+	segmentExt     = ".seg"
+	corruptExt     = ".corrupt"
+	tempExt        = ".tmp"
+
+	maxLabelLength = math.MaxUint16 // Longest label the uint16 label length can hold
+)
+
+var errCorruptSegment = errors.New("corrupt segment")
+
+// Segment is a batch persisted in the spool directory.
+type Segment struct {
+	Name    string // File name of the segment in the spool directory
+	Label   string // Stream load label of the batch
+	Payload []byte // Batch content
+	Size    int64  // Size of the segment file in bytes
+}
+
+// encodeSegment encodes the label and payload into the segment format. The label must not be longer than maxLabelLength.
+func encodeSegment(label string, payload []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(segmentMagic)
+	buffer.WriteByte(segmentVersion)
+	_ = binary.Write(&buffer, binary.BigEndian, uint16(len(label)))
+	buffer.WriteString(label)
+	_ = binary.Write(&buffer, binary.BigEndian, uint64(len(payload)))
+	buffer.Write(payload)
+	_ = binary.Write(&buffer, binary.BigEndian, crc32.ChecksumIEEE(buffer.Bytes()))
+
+	return buffer.Bytes()
+}
+
+// decodeSegment decodes a segment. It'll return errCorruptSegment if the segment is truncated or its checksum doesn't match.
+func decodeSegment(data []byte) (label string, payload []byte, err error) {
+	reader := bytes.NewReader(data)
+
+	header := make([]byte, len(segmentMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil || string(header[:len(segmentMagic)]) != segmentMagic || header[len(segmentMagic)] != segmentVersion {
+		return "", nil, errCorruptSegment
+	}
+
+	var labelLength uint16
+	if err := binary.Read(reader, binary.BigEndian, &labelLength); err != nil {
+		return "", nil, errCorruptSegment
+	}
+
+	labelBytes := make([]byte, labelLength)
+	if _, err := io.ReadFull(reader, labelBytes); err != nil {
+		return "", nil, errCorruptSegment
+	}
+
+	var payloadLength uint64
+	if err := binary.Read(reader, binary.BigEndian, &payloadLength); err != nil || payloadLength > uint64(reader.Len()) {
+		return "", nil, errCorruptSegment
+	}
+
+	payload = make([]byte, payloadLength)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return "", nil, errCorruptSegment
+	}
+
+	checked := len(data) - reader.Len()
+
+	var checksum uint32
+	if err := binary.Read(reader, binary.BigEndian, &checksum); err != nil || reader.Len() != 0 {
+		return "", nil, errCorruptSegment
+	}
+
+	if crc32.ChecksumIEEE(data[:checked]) != checksum {
+		return "", nil, errCorruptSegment
+	}
+
+	return string(labelBytes), payload, nil
+}
+
+// writeSegment writes the segment atomically: it's written to a temporary file, synced and renamed, so a crash never leaves a partial segment behind.
+func writeSegment(dir string, name string, data []byte) error {
+	tmp := filepath.Join(dir, name+tempExt)
+
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir flushes the directory entries, so that created and renamed files survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
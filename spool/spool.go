@@ -0,0 +1,330 @@
+package spool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/raaaaaaaay86/doris-loader/loader"
+)
+
+// Spool is a write-ahead spool of batches. A batch is persisted as a segment file in the spool directory before it's loaded, and the segment is deleted only after Doris reports it loaded. Segments left by a crashed process are loaded again by Replay with their original labels, so Doris label deduplication keeps the replay idempotent.
+type Spool struct {
+	Dir         string // Spool directory
+	MaxBytes    int64  // Maximum total size of the segments in bytes (default: 1GB)
+	MaxSegments int    // Maximum number of segments, 0 means unlimited (default: 0)
+
+	loader *loader.StreamLoader
+
+	mu       sync.Mutex
+	size     int64
+	segments map[string]int64
+	sequence int64
+}
+
+type Option func(*Spool) error
+
+// Open opens the spool directory, creating it if needed, and recovers the segments left in it. Incomplete writes are removed and segments failing the checksum are renamed with the ".corrupt" extension, so that they're neither replayed nor counted toward the limits.
+func Open(
+	dir string,
+	ld *loader.StreamLoader,
+	options ...Option,
+) (*Spool, error) {
+	if dir == "" {
+		return nil, loader.ErrMissingRequiredValue("Dir")
+	}
+
+	if ld == nil {
+		return nil, loader.ErrMissingRequiredValue("StreamLoader")
+	}
+
+	spool := Spool{
+		Dir:      dir,
+		MaxBytes: 1024 * 1024 * 1024,
+		loader:   ld,
+		segments: map[string]int64{},
+	}
+
+	for _, option := range options {
+		if err := option(&spool); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	if err := spool.recover(); err != nil {
+		return nil, err
+	}
+
+	return &spool, nil
+}
+
+// Load persists the batch in a segment and stream loads it with the label. It'll return ErrLabelTooLong if the label doesn't fit in the segment format. An empty label gets a generated one, which is persisted with the batch and reused by Replay. The segment is deleted once the batch is loaded; otherwise it's kept for Replay.
+func (s *Spool) Load(
+	ctx context.Context,
+	label string,
+	payload []byte,
+) (*loader.StreamLoadResult, error) {
+	if len(label) > maxLabelLength {
+		return nil, ErrLabelTooLong
+	}
+
+	name := s.reserve()
+
+	if label == "" {
+		label = "spool_" + strings.TrimSuffix(name, segmentExt)
+	}
+
+	data := encodeSegment(label, payload)
+	if err := s.admit(name, int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	if err := writeSegment(s.Dir, name, data); err != nil {
+		s.forget(name)
+		return nil, err
+	}
+
+	return s.load(ctx, Segment{
+		Name:    name,
+		Label:   label,
+		Payload: payload,
+		Size:    int64(len(data)),
+	})
+}
+
+// ReplayResult is the result of loading a leftover segment by Replay.
+type ReplayResult struct {
+	Segment Segment                  // Replayed segment, without its payload
+	Result  *loader.StreamLoadResult // Stream load result, nil if the request failed
+	Err     error                    // Request error, nil if the request was sent
+}
+
+// Replay loads the segments left in the spool in the order they were written, with their original labels. The segments are read and loaded one at a time, so that a large backlog isn't held in memory. Loaded segments are deleted and the others are kept for the next replay. It's meant to be called after Open, before new batches are loaded.
+func (s *Spool) Replay(ctx context.Context) ([]ReplayResult, error) {
+	names := s.Pending()
+
+	results := make([]ReplayResult, 0, len(names))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		segment, err := s.Read(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Loaded concurrently.
+		}
+		if err != nil {
+			return results, err
+		}
+
+		result, err := s.load(ctx, segment)
+		segment.Payload = nil
+		results = append(results, ReplayResult{
+			Segment: segment,
+			Result:  result,
+			Err:     err,
+		})
+	}
+
+	return results, nil
+}
+
+// Pending returns the names of the segments waiting in the spool in the order they were written.
+func (s *Spool) Pending() []string {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.segments))
+	for name := range s.segments {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Read reads a segment of the spool. It'll return an error wrapping os.ErrNotExist if the segment was loaded or discarded.
+func (s *Spool) Read(name string) (Segment, error) {
+	data, err := os.ReadFile(filepath.Join(s.Dir, name))
+	if err != nil {
+		return Segment{}, err
+	}
+
+	label, payload, err := decodeSegment(data)
+	if err != nil {
+		return Segment{}, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return Segment{
+		Name:    name,
+		Label:   label,
+		Payload: payload,
+		Size:    int64(len(data)),
+	}, nil
+}
+
+// Discard deletes a segment without loading it, for batches which Doris will never accept.
+func (s *Spool) Discard(name string) error {
+	if err := os.Remove(filepath.Join(s.Dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	s.forget(name)
+
+	return nil
+}
+
+// Size returns the total size of the segments in bytes.
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.size
+}
+
+// load stream loads the segment and deletes it if Doris reports it loaded, including by a previous attempt with the same label.
+func (s *Spool) load(ctx context.Context, segment Segment) (*loader.StreamLoadResult, error) {
+	result, err := s.loader.LoadReader(ctx, bytes.NewReader(segment.Payload), segment.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.IsLoaded() {
+		if err := s.Discard(segment.Name); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// reserve returns the name of a new segment. Names sort in the order they are reserved.
+func (s *Spool) reserve() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sequence = max(s.sequence+1, time.Now().UnixNano())
+
+	return fmt.Sprintf("%020d%s", s.sequence, segmentExt)
+}
+
+// admit accounts the segment toward the limits. It'll return ErrSpoolFull if the segment doesn't fit.
+func (s *Spool) admit(name string, size int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size+size > s.MaxBytes {
+		return ErrSpoolFull
+	}
+
+	if s.MaxSegments > 0 && len(s.segments) >= s.MaxSegments {
+		return ErrSpoolFull
+	}
+
+	s.segments[name] = size
+	s.size += size
+
+	return nil
+}
+
+// forget removes the segment from the accounting.
+func (s *Spool) forget(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size, ok := s.segments[name]; ok {
+		s.size -= size
+		delete(s.segments, name)
+	}
+}
+
+// recover scans the spool directory for the segments left by a previous process.
+func (s *Spool) recover() error {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		path := filepath.Join(s.Dir, entry.Name())
+
+		switch filepath.Ext(entry.Name()) {
+		case tempExt:
+			// Never renamed, so the batch was never attempted.
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		case segmentExt:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+
+			if _, _, err := decodeSegment(data); err != nil {
+				if err := os.Rename(path, path+corruptExt); err != nil {
+					return err
+				}
+				continue
+			}
+
+			s.segments[entry.Name()] = int64(len(data))
+			s.size += int64(len(data))
+
+			var sequence int64
+			if _, err := fmt.Sscanf(entry.Name(), "%d"+segmentExt, &sequence); err == nil {
+				s.sequence = max(s.sequence, sequence)
+			}
+		}
+	}
+
+	return nil
+}
+
+// WithMaxBytes sets the maximum total size of the segments in bytes. It'll return an error if there has any maximum size set before.
+func WithMaxBytes(size int64) Option {
+	return func(spool *Spool) error {
+		if size <= 0 {
+			return loader.ErrUnsupportValue("MaxBytes")
+		}
+
+		if spool.MaxBytes != 1024*1024*1024 && spool.MaxBytes != size { // 1GB is the default value
+			return loader.ErrAmbiguousOption("MaxBytes")
+		}
+
+		spool.MaxBytes = size
+
+		return nil
+	}
+}
+
+// WithMaxSegments sets the maximum number of segments. It'll return an error if there has any maximum number set before.
+func WithMaxSegments(count int) Option {
+	return func(spool *Spool) error {
+		if count <= 0 {
+			return loader.ErrUnsupportValue("MaxSegments")
+		}
+
+		if spool.MaxSegments != 0 && spool.MaxSegments != count {
+			return loader.ErrAmbiguousOption("MaxSegments")
+		}
+
+		spool.MaxSegments = count
+
+		return nil
+	}
+}
@@ -0,0 +1,179 @@
+package spool_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/raaaaaaaay86/doris-loader/loader"
+	"github.com/raaaaaaaay86/doris-loader/spool"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFe accepts stream loads unless it's down and records the labels it loaded.
+type fakeFe struct {
+	mu     sync.Mutex
+	down   bool
+	labels []string
+}
+
+func newFakeFe(t *testing.T) (*fakeFe, *loader.StreamLoader) {
+	t.Helper()
+
+	fe := &fakeFe{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+
+		fe.mu.Lock()
+		defer fe.mu.Unlock()
+
+		if fe.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		fe.labels = append(fe.labels, r.Header.Get("label"))
+		_, _ = w.Write([]byte(`{"Status": "Success"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	ld, err := loader.NewStreamLoader(
+		[]string{strings.TrimPrefix(server.URL, "http://")},
+		"test_db",
+		"users",
+		loader.WithMaxRetry(1),
+	)
+	assert.NoError(t, err)
+
+	return fe, ld
+}
+
+func segments(t *testing.T, dir string, ext string) []string {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+ext))
+	assert.NoError(t, err)
+
+	return matches
+}
+
+func TestSpoolDeletesLoadedSegment(t *testing.T) {
+	t.Log("a segment should be deleted once its batch is loaded")
+
+	fe, ld := newFakeFe(t)
+	dir := t.TempDir()
+
+	sp, err := spool.Open(dir, ld)
+	assert.NoError(t, err)
+
+	result, err := sp.Load(context.Background(), "batch_1", []byte(`{"name": "John Doe", "age": 30}`))
+	assert.NoError(t, err)
+	assert.True(t, result.IsSuccess())
+	assert.Equal(t, []string{"batch_1"}, fe.labels)
+	assert.Empty(t, segments(t, dir, ".seg"))
+	assert.Equal(t, int64(0), sp.Size())
+}
+
+func TestSpoolReplaysLeftoverSegments(t *testing.T) {
+	t.Log("segments of failed loads should be replayed with their original labels after reopening the spool")
+
+	fe, ld := newFakeFe(t)
+	dir := t.TempDir()
+
+	sp, err := spool.Open(dir, ld)
+	assert.NoError(t, err)
+
+	fe.down = true
+	_, err = sp.Load(context.Background(), "batch_1", []byte(`{"name": "John Doe", "age": 30}`))
+	assert.Error(t, err)
+	_, err = sp.Load(context.Background(), "", []byte(`{"name": "Jane Doe", "age": 31}`))
+	assert.Error(t, err)
+	assert.Len(t, segments(t, dir, ".seg"), 2)
+
+	pending := sp.Pending()
+	assert.Len(t, pending, 2)
+	segment, err := sp.Read(pending[1])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(`{"name": "Jane Doe", "age": 31}`), segment.Payload)
+	generated := segment.Label
+
+	fe.down = false
+	reopened, err := spool.Open(dir, ld)
+	assert.NoError(t, err)
+
+	results, err := reopened.Replay(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+	for _, result := range results {
+		assert.NoError(t, result.Err)
+		assert.True(t, result.Result.IsSuccess())
+		assert.Nil(t, result.Segment.Payload)
+	}
+
+	assert.Equal(t, []string{"batch_1", generated}, fe.labels)
+	assert.Empty(t, segments(t, dir, ".seg"))
+}
+
+func TestSpoolQuarantinesCorruptSegments(t *testing.T) {
+	t.Log("corrupt segments and incomplete writes should be set aside when the spool is opened")
+
+	fe, ld := newFakeFe(t)
+	dir := t.TempDir()
+
+	sp, err := spool.Open(dir, ld)
+	assert.NoError(t, err)
+
+	fe.down = true
+	_, err = sp.Load(context.Background(), "batch_1", []byte(`{"name": "John Doe", "age": 30}`))
+	assert.Error(t, err)
+	_, err = sp.Load(context.Background(), "batch_2", []byte(`{"name": "Jane Doe", "age": 31}`))
+	assert.Error(t, err)
+
+	written := segments(t, dir, ".seg")
+	data, err := os.ReadFile(written[1])
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(written[1], data[:len(data)-3], 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000001.seg.tmp"), []byte("partial"), 0o600))
+
+	fe.down = false
+	reopened, err := spool.Open(dir, ld)
+	assert.NoError(t, err)
+
+	results, err := reopened.Replay(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, []string{"batch_1"}, fe.labels)
+	assert.Len(t, segments(t, dir, ".corrupt"), 1)
+	assert.Empty(t, segments(t, dir, ".tmp"))
+}
+
+func TestSpoolLimits(t *testing.T) {
+	t.Log("batches should be rejected once the spool reaches its size limit")
+
+	fe, ld := newFakeFe(t)
+	dir := t.TempDir()
+
+	sp, err := spool.Open(dir, ld, spool.WithMaxSegments(1))
+	assert.NoError(t, err)
+
+	fe.down = true
+	_, err = sp.Load(context.Background(), "batch_1", []byte(`{"name": "John Doe", "age": 30}`))
+	assert.Error(t, err)
+
+	_, err = sp.Load(context.Background(), "batch_2", []byte(`{"name": "Jane Doe", "age": 31}`))
+	assert.ErrorIs(t, err, spool.ErrSpoolFull)
+
+	t.Log("a label too long for the segment format should be rejected before anything is written")
+	_, err = sp.Load(context.Background(), strings.Repeat("a", 65536), []byte(`{"name": "Jane Doe", "age": 31}`))
+	assert.ErrorIs(t, err, spool.ErrLabelTooLong)
+	assert.Len(t, segments(t, dir, ".seg"), 1)
+
+	_, err = spool.Open(dir, ld, spool.WithMaxBytes(1), spool.WithMaxBytes(2))
+	assert.EqualError(t, err, loader.ErrAmbiguousOption("MaxBytes").Error())
+}